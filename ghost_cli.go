@@ -0,0 +1,336 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/helium"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+const ghostUsage = `usage: rosetta-helium ghost <command> [flags] [args]
+
+Commands:
+  list   [--network] [--from] [--to]          list stored ghost transactions
+  show   [--network] <ghost txn hash>         print one ghost transaction
+  export [--network] [--out]                  write stored ghost transactions as a ghost transaction file
+  import [--network] <file>                   validate and store the ghost transactions in a file
+  verify [--network] [--from] [--to]          check stored ghost transactions against the node
+  quarantine [--network]                      list records quarantined because they did not decode
+  quarantine [--network] drop <id>            delete a quarantined record
+
+Flags must come before arguments. --network is mainnet (default) or testnet.
+`
+
+// runGhostCommand runs a `ghost` subcommand against the local store and
+// returns the process exit code.
+func runGhostCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, ghostUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("ghost "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	networkName := flags.String("network", "mainnet", "mainnet or testnet")
+	from := flags.Int64("from", 0, "first release height (0 for no bound)")
+	to := flags.Int64("to", 0, "last release height (0 for no bound)")
+	out := flags.String("out", "", "file to write (stdout if empty)")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	network, nerr := ghostNetwork(*networkName)
+	if nerr != nil {
+		fmt.Fprintln(stderr, nerr)
+		return 2
+	}
+	helium.CurrentNetwork = network
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = ghostList(stdout, network, *from, *to)
+	case "show":
+		if flags.NArg() != 1 {
+			fmt.Fprint(stderr, ghostUsage)
+			return 2
+		}
+		err = ghostShow(stdout, network, flags.Arg(0))
+	case "export":
+		err = ghostExport(stdout, stderr, network, *out)
+	case "import":
+		if flags.NArg() != 1 {
+			fmt.Fprint(stderr, ghostUsage)
+			return 2
+		}
+		err = ghostImport(stdout, network, flags.Arg(0))
+	case "verify":
+		var failed bool
+		failed, err = ghostVerify(stdout, network, *from, *to)
+		if err == nil && failed {
+			return 1
+		}
+	case "quarantine":
+		switch {
+		case flags.NArg() == 0:
+			err = ghostQuarantineList(stdout, network)
+		case flags.NArg() == 2 && flags.Arg(0) == "drop":
+			err = ghostQuarantineDrop(stdout, network, flags.Arg(1))
+		default:
+			fmt.Fprint(stderr, ghostUsage)
+			return 2
+		}
+	default:
+		fmt.Fprint(stderr, ghostUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "ghost "+args[0]+": "+err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func ghostNetwork(name string) (*types.NetworkIdentifier, error) {
+	switch strings.ToLower(name) {
+	case "mainnet":
+		return &types.NetworkIdentifier{Blockchain: "Helium", Network: helium.MainnetNetwork}, nil
+	case "testnet":
+		return &types.NetworkIdentifier{Blockchain: "Helium", Network: helium.TestnetNetwork}, nil
+	default:
+		return nil, errors.New("unknown network " + name + ", expected mainnet or testnet")
+	}
+}
+
+// storedGhostTxnsInRange lists the stored ghost transactions released
+// between from and to, where 0 leaves a bound open.
+func storedGhostTxnsInRange(network *types.NetworkIdentifier, from int64, to int64) ([]*utils.StoredGhostTxn, error) {
+	ghostTxns, err := utils.ListGhostTxns(network)
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []*utils.StoredGhostTxn
+	for _, ghostTxn := range ghostTxns {
+		height := ghostTxn.Key.Block.Index
+		if (from == 0 || height >= from) && (to == 0 || height <= to) {
+			inRange = append(inRange, ghostTxn)
+		}
+	}
+
+	return inRange, nil
+}
+
+func ghostList(stdout io.Writer, network *types.NetworkIdentifier, from int64, to int64) error {
+	ghostTxns, err := storedGhostTxnsInRange(network, from, to)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HASH\tRELEASE HEIGHT\tUNSTAKE BLOCK\tOWNER\tSTAKE")
+	for _, ghostTxn := range ghostTxns {
+		unstakeBlock := "unknown"
+		if ghostTxn.Metadata.UnstakeBlock != 0 {
+			unstakeBlock = fmt.Sprint(ghostTxn.Metadata.UnstakeBlock)
+		}
+
+		owner, stake := "unknown", "unknown"
+		if ghostTxn.Metadata.Metadata["owner"] != nil {
+			owner = fmt.Sprint(ghostTxn.Metadata.Metadata["owner"])
+		}
+		if ghostTxn.Metadata.Metadata["stake_amount"] != nil {
+			stake = fmt.Sprintf("%.0f", ghostTxn.Metadata.Metadata["stake_amount"])
+		}
+
+		fmt.Fprintln(w, utils.GhostTxnHash(ghostTxn.Key)+"\t"+fmt.Sprint(ghostTxn.Key.Block.Index)+"\t"+
+			unstakeBlock+"\t"+owner+"\t"+stake)
+	}
+	if ferr := w.Flush(); ferr != nil {
+		return ferr
+	}
+
+	fmt.Fprintln(stdout, fmt.Sprint(len(ghostTxns))+" ghost txns")
+	return nil
+}
+
+func ghostShow(stdout io.Writer, network *types.NetworkIdentifier, hash string) error {
+	key, ok := utils.ParseGhostTxnHash(network, hash)
+	if !ok {
+		return errors.New(hash + " is not a ghost txn hash (ghost_{release height}_{unstake hash})")
+	}
+
+	ghostTxnMetadata, err := utils.GetGhostTxn(key)
+	if err == badger.ErrKeyNotFound {
+		return errors.New(hash + " is not stored")
+	} else if err != nil {
+		return err
+	}
+
+	output, merr := json.MarshalIndent(map[string]interface{}{
+		"release_height": key.Block.Index,
+		"unstake_block":  ghostTxnMetadata.UnstakeBlock,
+		"transaction":    utils.GhostTransaction(key, ghostTxnMetadata),
+	}, "", "  ")
+	if merr != nil {
+		return merr
+	}
+
+	fmt.Fprintln(stdout, string(output))
+	return nil
+}
+
+func ghostExport(stdout io.Writer, stderr io.Writer, network *types.NetworkIdentifier, out string) error {
+	ghostTxns, err := utils.ListGhostTxns(network)
+	if err != nil {
+		return err
+	}
+
+	var records []*helium.GhostTxnRecord
+	for _, ghostTxn := range ghostTxns {
+		record, rerr := helium.GhostTxnRecordFromStored(ghostTxn)
+		if rerr != nil {
+			fmt.Fprintln(stderr, "skipping "+utils.GhostTxnHash(ghostTxn.Key)+": "+rerr.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+
+	w := stdout
+	if out != "" {
+		file, cerr := os.Create(out)
+		if cerr != nil {
+			return cerr
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if werr := helium.WriteGhostTxnFile(w, records); werr != nil {
+		return werr
+	}
+
+	fmt.Fprintln(stderr, "exported "+fmt.Sprint(len(records))+" of "+fmt.Sprint(len(ghostTxns))+" ghost txns")
+	return nil
+}
+
+func ghostImport(stdout io.Writer, network *types.NetworkIdentifier, path string) error {
+	records, perr := helium.ParseGhostTxnFile(path)
+	if perr != nil {
+		return perr
+	}
+
+	added := 0
+	for _, record := range records {
+		key, metadata, gerr := helium.GhostTxnFromRecord(network, record)
+		if gerr != nil {
+			return gerr
+		}
+
+		cerr := utils.CreateGhostTxn(key, metadata)
+		if cerr == badger.ErrBannedKey {
+			continue
+		} else if cerr != nil {
+			return cerr
+		}
+
+		if ierr := helium.InvalidateBlock(key.Block.Index); ierr != nil {
+			return ierr
+		}
+
+		added++
+		fmt.Fprintln(stdout, "added "+utils.GhostTxnHash(key))
+	}
+
+	fmt.Fprintln(stdout, "imported "+fmt.Sprint(len(records))+" ghost txns: "+fmt.Sprint(added)+" added, "+
+		fmt.Sprint(len(records)-added)+" already stored")
+	return nil
+}
+
+func ghostVerify(stdout io.Writer, network *types.NetworkIdentifier, from int64, to int64) (bool, error) {
+	ghostTxns, err := storedGhostTxnsInRange(network, from, to)
+	if err != nil {
+		return false, err
+	}
+
+	mismatched := 0
+	for _, ghostTxn := range ghostTxns {
+		problems, vErr := helium.VerifyGhostTxn(ghostTxn)
+		if vErr != nil {
+			return false, errors.New(utils.GhostTxnHash(ghostTxn.Key) + ": " + vErr.Message + helium.ErrorContext(vErr))
+		}
+
+		if len(problems) > 0 {
+			mismatched++
+			fmt.Fprintln(stdout, utils.GhostTxnHash(ghostTxn.Key)+":")
+			for _, problem := range problems {
+				fmt.Fprintln(stdout, "  "+problem)
+			}
+		}
+	}
+
+	fmt.Fprintln(stdout, "verified "+fmt.Sprint(len(ghostTxns))+" ghost txns: "+fmt.Sprint(mismatched)+" with mismatches")
+	return mismatched > 0, nil
+}
+
+func ghostQuarantineList(stdout io.Writer, network *types.NetworkIdentifier) error {
+	records, err := utils.ListQuarantined(network)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		fmt.Fprintln(stdout, record.ID()+":")
+		fmt.Fprintln(stdout, "  height:      "+fmt.Sprint(record.Height))
+		fmt.Fprintln(stdout, "  quarantined: "+time.Unix(record.Time, 0).UTC().Format(time.RFC3339))
+		fmt.Fprintln(stdout, "  error:       "+record.Error)
+		fmt.Fprintln(stdout, "  value:       "+fmt.Sprintf("%q", record.Value))
+	}
+
+	fmt.Fprintln(stdout, fmt.Sprint(len(records))+" quarantined records")
+	return nil
+}
+
+func ghostQuarantineDrop(stdout io.Writer, network *types.NetworkIdentifier, id string) error {
+	height, key, perr := utils.ParseQuarantineID(id)
+	if perr != nil {
+		return perr
+	}
+
+	err := utils.DropQuarantined(network, height, key)
+	if err == badger.ErrKeyNotFound {
+		return errors.New(id + " is not quarantined")
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "dropped "+id)
+	return nil
+}
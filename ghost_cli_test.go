@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/helium"
+	"github.com/helium/rosetta-helium/utils"
+	"github.com/helium/rosetta-helium/utils/testdb"
+)
+
+// openTestDB opens an in-memory store and points helium.CurrentNetwork at
+// mainnet for the duration of a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	testdb.Open(t)
+
+	previous := helium.CurrentNetwork
+	helium.CurrentNetwork = &types.NetworkIdentifier{Blockchain: "Helium", Network: helium.MainnetNetwork}
+	t.Cleanup(func() { helium.CurrentNetwork = previous })
+}
+
+func TestGhostQuarantineFlow(t *testing.T) {
+	openTestDB(t)
+
+	// A ghost transaction whose payload does not decode
+	if err := utils.PutDeferredEffect(helium.CurrentNetwork, &utils.DeferredEffect{
+		Kind:    utils.GhostTxnKind,
+		Height:  10,
+		Source:  "src",
+		Payload: []byte(`"not metadata"`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading the block quarantines it and fails with its id
+	_, dErr := helium.DeferredTransactions(10)
+	if dErr == nil || dErr.Code != helium.ErrFailed.Code {
+		t.Fatalf("got %v, want ErrFailed", dErr)
+	}
+	ids, _ := dErr.Details["quarantined"].([]string)
+	if len(ids) != 1 || !strings.HasPrefix(ids[0], "10_") {
+		t.Fatalf("got quarantined ids %v", dErr.Details["quarantined"])
+	}
+
+	// Later reads keep failing with the same id
+	_, dErr = helium.DeferredTransactions(10)
+	if dErr == nil || !reflect.DeepEqual(dErr.Details["quarantined"], ids) {
+		t.Fatalf("second read: got %v", dErr)
+	}
+
+	response, cErr := helium.Call(helium.QuarantineCall, map[string]interface{}{})
+	if cErr != nil {
+		t.Fatal(cErr)
+	}
+	records := response.Result["records"].([]interface{})
+	if len(records) != 1 || records[0].(map[string]interface{})["id"] != ids[0] {
+		t.Fatalf("%s: got %v", helium.QuarantineCall, records)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runGhostCommand([]string{"quarantine"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("ghost quarantine: exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), ids[0]+":") {
+		t.Fatalf("ghost quarantine: %s does not list %s", stdout.String(), ids[0])
+	}
+
+	stdout.Reset()
+	if code := runGhostCommand([]string{"quarantine", "drop", ids[0]}, &stdout, &stderr); code != 0 {
+		t.Fatalf("ghost quarantine drop: exit %d: %s", code, stderr.String())
+	}
+
+	// Once dropped, the block is served without it
+	transactions, dErr := helium.DeferredTransactions(10)
+	if dErr != nil || len(transactions) != 0 {
+		t.Fatalf("after drop: got %v, %v", transactions, dErr)
+	}
+
+	if code := runGhostCommand([]string{"quarantine", "drop", ids[0]}, &stdout, &stderr); code != 1 {
+		t.Fatalf("dropping twice: exit %d, want 1", code)
+	}
+}
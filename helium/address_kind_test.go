@@ -0,0 +1,104 @@
+package helium
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ybbus/jsonrpc"
+)
+
+// resetAddressKinds empties the address kind cache for a test.
+func resetAddressKinds(t *testing.T) {
+	addressKinds = map[string]*addressKindEntry{}
+	atomic.StoreInt32(&validatorInfoMissing, 0)
+	t.Cleanup(func() {
+		addressKinds = map[string]*addressKindEntry{}
+		atomic.StoreInt32(&validatorInfoMissing, 0)
+	})
+}
+
+// addedAt answers an info call for address as found from height added on.
+func addedAt(address string, added int64) nodeHandler {
+	return func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+		height, _ := strconv.ParseInt(fmt.Sprint(params["height"]), 10, 64)
+		if params["address"] != address || height < added {
+			return nodeNotFound(params)
+		}
+		return map[string]interface{}{"owner_address": "owner"}, nil
+	}
+}
+
+func TestGetAddressKind(t *testing.T) {
+	resetAddressKinds(t)
+	node := startTestNode(t, map[string]nodeHandler{
+		"gateway_info_get":  addedAt("gateway", 100),
+		ValidatorInfoMethod: addedAt("validator", 100),
+	})
+
+	tests := []struct {
+		address string
+		height  int64
+		want    string
+		// nodeCalls is how many info calls the lookup makes
+		nodeCalls int
+	}{
+		{"gateway", 150, GatewayAddress, 1},
+		{"gateway", 200, GatewayAddress, 0},
+		// Before the gateway was added it was a wallet
+		{"gateway", 50, WalletAddress, 2},
+		{"gateway", 40, WalletAddress, 0},
+		// Below the lowest height it was seen as a gateway it is asked again
+		{"gateway", 120, GatewayAddress, 1},
+		{"gateway", 130, GatewayAddress, 0},
+		{"gateway", 80, WalletAddress, 2},
+		{"gateway", 100, GatewayAddress, 1},
+		{"validator", 100, ValidatorAddress, 2},
+		{"validator", 99, WalletAddress, 2},
+		{"wallet", 100, WalletAddress, 2},
+		{"wallet", 90, WalletAddress, 0},
+	}
+
+	for _, test := range tests {
+		before := node.callCount("gateway_info_get") + node.callCount(ValidatorInfoMethod)
+
+		kind, kErr := GetAddressKind(test.address, test.height)
+		if kErr != nil {
+			t.Fatal(kErr)
+		}
+
+		calls := node.callCount("gateway_info_get") + node.callCount(ValidatorInfoMethod) - before
+		if kind != test.want || calls != test.nodeCalls {
+			t.Errorf("%s at %d: got %q with %d node calls, want %q with %d",
+				test.address, test.height, kind, calls, test.want, test.nodeCalls)
+		}
+	}
+}
+
+func TestAccountMetadataWithoutValidatorInfo(t *testing.T) {
+	resetAddressKinds(t)
+	node := startTestNode(t, map[string]nodeHandler{
+		"gateway_info_get": addedAt("gateway", 0),
+	})
+
+	for i := 0; i < 2; i++ {
+		metadata, mErr := GetAccountMetadata(&GetBalanceResponse{Address: "wallet", Nonce: 3}, 100)
+		if mErr != nil {
+			t.Fatal(mErr)
+		}
+		if _, ok := metadata["address_kind"]; ok || metadata["nonce"] != int64(3) {
+			t.Fatalf("got %v, want the nonce without an address_kind", metadata)
+		}
+	}
+
+	if calls := node.callCount(ValidatorInfoMethod); calls != 1 {
+		t.Fatalf("%s called %d times, want 1", ValidatorInfoMethod, calls)
+	}
+
+	// Gateways are still reported
+	metadata, _ := GetAccountMetadata(&GetBalanceResponse{Address: "gateway"}, 100)
+	if metadata["address_kind"] != GatewayAddress {
+		t.Fatalf("gateway: got %v", metadata)
+	}
+}
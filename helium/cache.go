@@ -0,0 +1,85 @@
+package helium
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+	"go.uber.org/zap"
+)
+
+// getCachedBlock returns the stored block for blockIdentifier, or nil if it
+// has not been built under the current ParserVersion yet.
+func getCachedBlock(blockIdentifier *types.PartialBlockIdentifier) *types.Block {
+	var block *types.Block
+	var err error
+
+	if blockIdentifier == nil {
+		return nil
+	}
+
+	if blockIdentifier.Index != nil {
+		block, err = utils.GetBlockByHeight(CurrentNetwork, ParserVersion, *blockIdentifier.Index)
+	} else if blockIdentifier.Hash != nil && len(*blockIdentifier.Hash) <= utils.MaxKeyStringLength {
+		block, err = utils.GetBlockByHash(CurrentNetwork, ParserVersion, *blockIdentifier.Hash)
+	} else {
+		return nil
+	}
+
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			zap.S().Warn("Unable to read stored block: " + err.Error())
+		}
+		return nil
+	}
+
+	return block
+}
+
+// cacheBlock stores and indexes block, unless it is within IrreversibleDepth
+// of the node's tip. Blocks that close to the tip could still be replaced, and
+// a stored block is served by height without asking the node again. A block
+// that cannot be stored or indexed is an error, so that the indexer does not
+// move past it and leave it out of search.
+func cacheBlock(block *types.Block) *types.Error {
+	tipHeight, chErr := GetCurrentHeight()
+	if chErr != nil {
+		return chErr
+	}
+	if block.BlockIdentifier.Index > *tipHeight-IrreversibleDepth {
+		return nil
+	}
+
+	// A block replacing another at its height, e.g. after a reorg, must not
+	// leave the old block's hash or search entries behind
+	if err := InvalidateBlock(block.BlockIdentifier.Index); err != nil {
+		return WrapErr(ErrFailed, errors.New("unable to clear block "+fmt.Sprint(block.BlockIdentifier.Index)+": "+err.Error()))
+	}
+
+	if err := utils.PutBlock(CurrentNetwork, ParserVersion, block); err != nil {
+		return WrapErr(ErrFailed, errors.New("unable to store block "+fmt.Sprint(block.BlockIdentifier.Index)+": "+err.Error()))
+	}
+
+	if err := IndexBlock(block); err != nil {
+		// Leave nothing stored for the block, so the next request for it
+		// builds and indexes it again
+		if iErr := InvalidateBlock(block.BlockIdentifier.Index); iErr != nil {
+			zap.S().Warn("Unable to clear block " + fmt.Sprint(block.BlockIdentifier.Index) + ": " + iErr.Error())
+		}
+		return WrapErr(ErrFailed, errors.New("unable to index block "+fmt.Sprint(block.BlockIdentifier.Index)+": "+err.Error()))
+	}
+
+	return nil
+}
+
+// InvalidateBlock drops the stored block at height and its search entries so
+// that it is rebuilt, picking up any ghost transactions added there since it
+// was stored.
+func InvalidateBlock(height int64) error {
+	if err := utils.DeleteBlock(CurrentNetwork, ParserVersion, height); err != nil {
+		return err
+	}
+	return utils.DeleteSearchEntries(CurrentNetwork, ParserVersion, height)
+}
@@ -0,0 +1,59 @@
+package helium
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ybbus/jsonrpc"
+)
+
+func TestGetBlockStoresIrreversibleBlocksOnly(t *testing.T) {
+	openTestDB(t)
+
+	tipHeight := int64(100)
+	node := startTestNode(t, map[string]nodeHandler{
+		"block_height": func(map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			return tipHeight, nil
+		},
+		"block_get": func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			height := int64(params["height"].(float64))
+			return map[string]interface{}{
+				"hash":         "hash" + fmt.Sprint(height),
+				"height":       height,
+				"prev_hash":    "prev",
+				"time":         1600000000,
+				"transactions": []interface{}{},
+			}, nil
+		},
+	})
+
+	tests := []struct {
+		height    int64
+		blockGets int
+	}{
+		// At the tip: rebuilt on every request
+		{height: 100, blockGets: 2},
+		{height: 100 - IrreversibleDepth + 1, blockGets: 2},
+		// Deep enough to be stored after the first request
+		{height: 100 - IrreversibleDepth, blockGets: 1},
+		{height: 50, blockGets: 1},
+	}
+
+	for _, test := range tests {
+		before := node.callCount("block_get")
+		for i := 0; i < 2; i++ {
+			height := test.height
+			block, bErr := GetBlock(&types.PartialBlockIdentifier{Index: &height})
+			if bErr != nil {
+				t.Fatalf("height %d: %v", test.height, bErr)
+			}
+			if block.BlockIdentifier.Index != test.height {
+				t.Fatalf("height %d: got block %d", test.height, block.BlockIdentifier.Index)
+			}
+		}
+		if got := node.callCount("block_get") - before; got != test.blockGets {
+			t.Errorf("height %d: block_get called %d times, want %d", test.height, got, test.blockGets)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package helium
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// Call runs one of the CallMethods. Results that are pinned to a requested
+// height are idempotent; anything read at the current height is not.
+func Call(method string, parameters map[string]interface{}) (*types.CallResponse, *types.Error) {
+	switch method {
+	case OraclePriceCall:
+		height, idempotent, hErr := heightParameter(parameters)
+		if hErr != nil {
+			return nil, hErr
+		}
+
+		price, pErr := GetOraclePrice(height)
+		if pErr != nil {
+			return nil, pErr
+		}
+
+		return &types.CallResponse{
+			Result: map[string]interface{}{
+				"height": height,
+				"price":  *price,
+			},
+			Idempotent: idempotent,
+		}, nil
+
+	case HTLCCall:
+		address, aErr := addressParameter(parameters)
+		if aErr != nil {
+			return nil, aErr
+		}
+
+		receipt, rErr := GetHTLCReceipt(address)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		return &types.CallResponse{
+			Result: map[string]interface{}{
+				"address":     receipt.Address,
+				"balance":     receipt.Balance,
+				"hashlock":    receipt.Hashlock,
+				"payee":       receipt.Payee,
+				"payer":       receipt.Payer,
+				"redeemed_at": receipt.RedeemedAt,
+				"timelock":    receipt.Timelock,
+			},
+			Idempotent: false,
+		}, nil
+
+	case GatewayInfoCall, ValidatorInfoCall:
+		address, aErr := addressParameter(parameters)
+		if aErr != nil {
+			return nil, aErr
+		}
+
+		height, idempotent, hErr := heightParameter(parameters)
+		if hErr != nil {
+			return nil, hErr
+		}
+
+		lookup := GetGatewayInfo
+		if method == ValidatorInfoCall {
+			lookup = GetValidator
+		}
+
+		result, lErr := lookup(address, height)
+		if lErr != nil {
+			return nil, lErr
+		}
+
+		return &types.CallResponse{
+			Result:     result,
+			Idempotent: idempotent,
+		}, nil
+
+	case AccountNonceCall:
+		address, aErr := addressParameter(parameters)
+		if aErr != nil {
+			return nil, aErr
+		}
+
+		nonce, nErr := GetNonce(address)
+		if nErr != nil {
+			return nil, nErr
+		}
+
+		return &types.CallResponse{
+			Result: map[string]interface{}{
+				"address": address,
+				"nonce":   *nonce,
+			},
+			Idempotent: false,
+		}, nil
+
+	case ChainVarsCall:
+		if len(parameters) != 0 {
+			return nil, WrapErr(ErrInvalidParameter, errors.New(ChainVarsCall+" takes no parameters"))
+		}
+
+		chainVars, vErr := GetChainVars()
+		if vErr != nil {
+			return nil, WrapErr(ErrFailed, vErr)
+		}
+
+		return &types.CallResponse{
+			Result:     chainVars,
+			Idempotent: false,
+		}, nil
+
+	case QuarantineCall:
+		if len(parameters) != 0 {
+			return nil, WrapErr(ErrInvalidParameter, errors.New(QuarantineCall+" takes no parameters"))
+		}
+
+		records, qErr := utils.ListQuarantined(CurrentNetwork)
+		if qErr != nil {
+			return nil, WrapErr(ErrFailed, qErr)
+		}
+
+		quarantined := []interface{}{}
+		for _, record := range records {
+			quarantined = append(quarantined, map[string]interface{}{
+				"id":     record.ID(),
+				"height": record.Height,
+				"value":  string(record.Value),
+				"error":  record.Error,
+				"time":   record.Time,
+			})
+		}
+
+		return &types.CallResponse{
+			Result: map[string]interface{}{
+				"records": quarantined,
+			},
+			Idempotent: false,
+		}, nil
+
+	default:
+		return nil, WrapErr(ErrInvalidParameter, errors.New("unsupported call method: "+method))
+	}
+}
+
+// addressParameter returns the required `address` parameter.
+func addressParameter(parameters map[string]interface{}) (string, *types.Error) {
+	address, ok := parameters["address"].(string)
+	if !ok || address == "" {
+		return "", WrapErr(ErrInvalidParameter, errors.New("`address` must be a non-empty string"))
+	}
+
+	return address, nil
+}
+
+// heightParameter returns the optional `height` parameter, defaulting to the
+// current height, and whether it was given.
+func heightParameter(parameters map[string]interface{}) (int64, bool, *types.Error) {
+	rawHeight, ok := parameters["height"]
+	if !ok {
+		currentHeight, chErr := GetCurrentHeight()
+		if chErr != nil {
+			return 0, false, chErr
+		}
+		return *currentHeight, false, nil
+	}
+
+	// Parameters are decoded from JSON, so numbers arrive as float64
+	height, ok := rawHeight.(float64)
+	if !ok || height != float64(int64(height)) || height < 1 {
+		return 0, false, WrapErr(ErrInvalidParameter, errors.New("`height` must be a positive integer, got "+fmt.Sprint(rawHeight)))
+	}
+
+	return int64(height), true, nil
+}
@@ -0,0 +1,20 @@
+package helium
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils/testdb"
+)
+
+// openTestDB opens an in-memory store and points CurrentNetwork at mainnet
+// for the duration of a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	testdb.Open(t)
+
+	previous := CurrentNetwork
+	CurrentNetwork = &types.NetworkIdentifier{Blockchain: "Helium", Network: MainnetNetwork}
+	t.Cleanup(func() { CurrentNetwork = previous })
+}
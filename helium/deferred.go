@@ -0,0 +1,233 @@
+package helium
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// DeferredKind describes one kind of deferred effect: a balance change the
+// chain applies at a later height than the transaction that causes it.
+type DeferredKind struct {
+	// Name is the kind's key prefix in the deferred effect store.
+	Name string
+	// HashPrefix starts the hash of every transaction of this kind, which is
+	// "{HashPrefix}{height}_{source hash}".
+	HashPrefix string
+	// TxnType is the transaction type the kind is searched by.
+	TxnType string
+	// Transaction builds the transaction reported for an effect at the
+	// height it is due, or returns nil if the effect no longer applies.
+	Transaction func(effect *utils.DeferredEffect) (*types.Transaction, *types.Error)
+}
+
+// DeferredKinds is every registered kind of deferred effect. Blocks report
+// the effects of all of them that are due at their height.
+var DeferredKinds = []*DeferredKind{
+	{
+		Name:       utils.GhostTxnKind,
+		HashPrefix: utils.GhostTxnHashPrefix,
+		TxnType:    GhostTxn,
+		Transaction: func(effect *utils.DeferredEffect) (*types.Transaction, *types.Error) {
+			ghostTxn, err := utils.GhostTxnFromEffect(CurrentNetwork, effect)
+			if err != nil {
+				return nil, deferredErr(effect.Height, utils.QuarantineDeferredEffect(CurrentNetwork, effect, err))
+			}
+			return utils.GhostTransaction(ghostTxn.Key, ghostTxn.Metadata), nil
+		},
+	},
+	{
+		Name:        HTLCTimeoutKind,
+		HashPrefix:  HTLCTimeoutKind + "_",
+		TxnType:     HTLCTimeoutTxn,
+		Transaction: htlcTimeoutTransaction,
+	},
+	{
+		Name:        StateChannelExpiryKind,
+		HashPrefix:  StateChannelExpiryKind + "_",
+		TxnType:     StateChannelExpiryTxn,
+		Transaction: stateChannelExpiryTransaction,
+	},
+	{
+		Name:        StakeTransferCooldownKind,
+		HashPrefix:  StakeTransferCooldownKind + "_",
+		TxnType:     StakeTransferCooldownTxn,
+		Transaction: stakeTransferCooldownTransaction,
+	},
+}
+
+// deferredKindOfHash returns the kind whose transaction hashes hash starts
+// like, or nil.
+func deferredKindOfHash(hash string) *DeferredKind {
+	for _, kind := range DeferredKinds {
+		if strings.HasPrefix(hash, kind.HashPrefix) {
+			return kind
+		}
+	}
+	return nil
+}
+
+func isDeferredTxnType(txnType string) bool {
+	for _, kind := range DeferredKinds {
+		if kind.TxnType == txnType {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDeferredTxnHash splits a deferred transaction hash into its kind,
+// height and source hash.
+func parseDeferredTxnHash(hash string) (*DeferredKind, int64, string, bool) {
+	kind := deferredKindOfHash(hash)
+	if kind == nil {
+		return nil, 0, "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(hash, kind.HashPrefix), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, 0, "", false
+	}
+
+	height, perr := strconv.ParseInt(parts[0], 10, 64)
+	if perr != nil {
+		return nil, 0, "", false
+	}
+
+	return kind, height, parts[1], true
+}
+
+// ScheduleDeferredEffect stores an effect of kind caused by the transaction
+// source and due at height. It returns false if the effect was already
+// stored.
+func ScheduleDeferredEffect(kind string, height int64, source string, payload []byte) (bool, *types.Error) {
+	err := utils.PutDeferredEffect(CurrentNetwork, &utils.DeferredEffect{
+		Kind:    kind,
+		Height:  height,
+		Source:  source,
+		Payload: payload,
+	})
+	if err == badger.ErrBannedKey {
+		return false, nil
+	} else if err != nil {
+		return false, WrapErr(ErrFailed, err)
+	}
+
+	// A block built before the effect was stored does not report it
+	if iErr := InvalidateBlock(height); iErr != nil {
+		return true, WrapErr(ErrFailed, iErr)
+	}
+
+	return true, nil
+}
+
+// deferredErr reports a failure to read the deferred effects due at height
+// as ErrFailed. Records that were quarantined are listed in its details.
+func deferredErr(height int64, err error) *types.Error {
+	dErr := WrapErr(ErrFailed, err)
+	dErr.Details["height"] = height
+
+	var quarantineErr *utils.QuarantineError
+	if errors.As(err, &quarantineErr) {
+		var ids []string
+		for _, record := range quarantineErr.Records {
+			ids = append(ids, record.ID())
+		}
+		dErr.Details["quarantined"] = ids
+	}
+
+	return dErr
+}
+
+// checkQuarantine fails while records due at height are quarantined, so that
+// the block is not served without them.
+func checkQuarantine(height int64) *types.Error {
+	records, err := utils.QuarantinedAt(CurrentNetwork, height)
+	if err != nil {
+		return deferredErr(height, err)
+	}
+	if len(records) > 0 {
+		return deferredErr(height, &utils.QuarantineError{Records: records})
+	}
+	return nil
+}
+
+// DeferredTransactions returns the transactions of every kind of deferred
+// effect due at height.
+func DeferredTransactions(height int64) ([]*types.Transaction, *types.Error) {
+	if qErr := checkQuarantine(height); qErr != nil {
+		return nil, qErr
+	}
+
+	var transactions []*types.Transaction
+
+	for _, kind := range DeferredKinds {
+		effects, err := utils.SeekDeferredEffects(CurrentNetwork, kind.Name, height)
+		if err != nil {
+			return nil, deferredErr(height, err)
+		}
+
+		for _, effect := range effects {
+			transaction, tErr := kind.Transaction(effect)
+			if tErr != nil {
+				return nil, tErr
+			} else if transaction == nil {
+				continue
+			}
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	return transactions, nil
+}
+
+// deferredTxnCounts counts transactions by the name of their deferred kind.
+// Every registered kind is listed, with zero if none of its transactions are
+// in transactions.
+func deferredTxnCounts(transactions []*types.Transaction) map[string]int {
+	counts := map[string]int{}
+	for _, kind := range DeferredKinds {
+		counts[kind.Name] = 0
+	}
+
+	for _, transaction := range transactions {
+		if kind := deferredKindOfHash(transaction.TransactionIdentifier.Hash); kind != nil {
+			counts[kind.Name]++
+		}
+	}
+
+	return counts
+}
+
+// DeferredTransaction returns the deferred transaction hash due at height, or
+// ErrNotFound.
+func DeferredTransaction(hash string, height int64) (*types.Transaction, *types.Error) {
+	kind, dueHeight, source, ok := parseDeferredTxnHash(hash)
+	if !ok || dueHeight != height {
+		return nil, WrapErr(ErrNotFound, errors.New(hash+" is not a deferred transaction due at this height"))
+	}
+
+	if qErr := checkQuarantine(height); qErr != nil {
+		return nil, qErr
+	}
+
+	effect, err := utils.GetDeferredEffect(CurrentNetwork, kind.Name, dueHeight, source)
+	if err == badger.ErrKeyNotFound {
+		return nil, WrapErr(ErrNotFound, err)
+	} else if err != nil {
+		return nil, deferredErr(height, err)
+	}
+
+	transaction, tErr := kind.Transaction(effect)
+	if tErr != nil {
+		return nil, tErr
+	} else if transaction == nil {
+		return nil, WrapErr(ErrNotFound, errors.New(hash+" no longer applies"))
+	}
+
+	return transaction, nil
+}
@@ -0,0 +1,275 @@
+package helium
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// Names of the deferred kinds other than unstakes. Each is also the key
+// prefix of its effects, and followed by "_" the hash prefix of its
+// transactions.
+const (
+	HTLCTimeoutKind           = "htlc_timeout"
+	StateChannelExpiryKind    = "state_channel_expiry"
+	StakeTransferCooldownKind = "stake_transfer_cooldown"
+)
+
+// deferredTransaction is the transaction reported for effect, which links
+// back to the transaction that caused it.
+func deferredTransaction(hashPrefix, txnType string, effect *utils.DeferredEffect, operations []*types.Operation) *types.Transaction {
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: hashPrefix + strconv.FormatInt(effect.Height, 10) + "_" + effect.Source,
+		},
+		Operations: operations,
+		RelatedTransactions: []*types.RelatedTransaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: effect.Source},
+				Direction:             types.Backward,
+			},
+		},
+		Metadata: map[string]interface{}{
+			"type": txnType,
+		},
+	}
+}
+
+// decodeDeferredPayload decodes effect's payload into payload, quarantining
+// the effect if it does not decode.
+func decodeDeferredPayload(effect *utils.DeferredEffect, payload interface{}) *types.Error {
+	if err := json.Unmarshal(effect.Payload, payload); err != nil {
+		return deferredErr(effect.Height, utils.QuarantineDeferredEffect(CurrentNetwork, effect, err))
+	}
+	return nil
+}
+
+// scheduleDeferred stores the effect of kind caused by the transaction hash.
+func scheduleDeferred(kind string, height int64, hash string, payload interface{}) *types.Error {
+	payloadBytes, mErr := json.Marshal(payload)
+	if mErr != nil {
+		return WrapErr(ErrFailed, mErr)
+	}
+
+	_, sErr := ScheduleDeferredEffect(kind, height, hash, payloadBytes)
+	return sErr
+}
+
+type htlcTimeoutPayload struct {
+	Address string `json:"address"`
+	Payer   string `json:"payer"`
+	Payee   string `json:"payee"`
+	Amount  int64  `json:"amount"`
+}
+
+// scheduleHTLCTimeout stores the timeout of the HTLC created by the
+// create_htlc_v1 txn in block. The timelock is the height from which the
+// payer can take the escrow back.
+func scheduleHTLCTimeout(txn map[string]interface{}, block *types.BlockIdentifier) *types.Error {
+	timelock := utils.JsonNumberToInt64(txn["timelock"])
+	if block == nil || timelock <= block.Index {
+		return nil
+	}
+
+	return scheduleDeferred(HTLCTimeoutKind, timelock, fmt.Sprint(txn["hash"]), &htlcTimeoutPayload{
+		Address: fmt.Sprint(txn["address"]),
+		Payer:   fmt.Sprint(txn["payer"]),
+		Payee:   fmt.Sprint(txn["payee"]),
+		Amount:  utils.JsonNumberToInt64(txn["amount"]),
+	})
+}
+
+// htlcTimeoutTransaction reports an HTLC becoming refundable to its payer. It
+// moves no funds, since the escrow stays at the HTLC address until a redeem,
+// and is skipped if the HTLC was redeemed by the timelock.
+func htlcTimeoutTransaction(effect *utils.DeferredEffect) (*types.Transaction, *types.Error) {
+	var payload htlcTimeoutPayload
+	if dErr := decodeDeferredPayload(effect, &payload); dErr != nil {
+		return nil, dErr
+	}
+
+	redeem, rErr := utils.GetHTLCTxn(CurrentNetwork, payload.Address, utils.HTLCRedeemStage)
+	if rErr == nil && redeem.Block != nil && redeem.Block.Index <= effect.Height {
+		return nil, nil
+	} else if rErr != nil && rErr != badger.ErrKeyNotFound {
+		return nil, WrapErr(ErrFailed, rErr)
+	}
+
+	timeout, _ := CreateGenericOp(HTLCTimeoutOp, SuccessStatus, 0, map[string]interface{}{
+		"payer":  payload.Payer,
+		"payee":  payload.Payee,
+		"amount": payload.Amount,
+	})
+	timeout.Account = &types.AccountIdentifier{Address: payload.Address}
+
+	return deferredTransaction(HTLCTimeoutKind+"_", HTLCTimeoutTxn, effect, []*types.Operation{timeout}), nil
+}
+
+// cancelHTLCTimeout rebuilds the block at the timelock of the HTLC at address
+// if it was redeemed at redeemHeight, before the timeout, so that the block
+// drops it.
+func cancelHTLCTimeout(address string, redeemHeight int64) *types.Error {
+	receipt, rErr := GetHTLCReceipt(address)
+	if rErr != nil {
+		return rErr
+	}
+
+	if redeemHeight <= receipt.Timelock {
+		if iErr := InvalidateBlock(receipt.Timelock); iErr != nil {
+			return WrapErr(ErrFailed, iErr)
+		}
+	}
+
+	return nil
+}
+
+type stateChannelExpiryPayload struct {
+	Owner  string `json:"owner"`
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+}
+
+// scheduleStateChannelExpiry stores the expiry of the state channel opened
+// by the state_channel_open_v1 txn in block, expire_within blocks later.
+func scheduleStateChannelExpiry(txn map[string]interface{}, block *types.BlockIdentifier) *types.Error {
+	if block == nil {
+		return nil
+	}
+
+	owner, id := fmt.Sprint(txn["owner"]), fmt.Sprint(txn["id"])
+	expiry := block.Index + utils.JsonNumberToInt64(txn["expire_within"])
+
+	if err := utils.PutStateChannelHeight(CurrentNetwork, owner, id, utils.StateChannelExpiryStage, expiry); err != nil {
+		return WrapErr(ErrFailed, err)
+	}
+
+	return scheduleDeferred(StateChannelExpiryKind, expiry, fmt.Sprint(txn["hash"]), &stateChannelExpiryPayload{
+		Owner:  owner,
+		ID:     id,
+		Amount: utils.JsonNumberToInt64(txn["amount"]),
+	})
+}
+
+// stateChannelExpiryTransaction reports a state channel expiring. It moves no
+// DC, since what was not spent is only refunded by the channel's
+// state_channel_close_v1, and is skipped if the channel was closed by then.
+func stateChannelExpiryTransaction(effect *utils.DeferredEffect) (*types.Transaction, *types.Error) {
+	var payload stateChannelExpiryPayload
+	if dErr := decodeDeferredPayload(effect, &payload); dErr != nil {
+		return nil, dErr
+	}
+
+	closedAt, cErr := utils.GetStateChannelHeight(CurrentNetwork, payload.Owner, payload.ID, utils.StateChannelCloseStage)
+	if cErr == nil && closedAt <= effect.Height {
+		return nil, nil
+	} else if cErr != nil && cErr != badger.ErrKeyNotFound {
+		return nil, WrapErr(ErrFailed, cErr)
+	}
+
+	expiry, _ := CreateGenericOp(StateChannelExpiryOp, SuccessStatus, 0, map[string]interface{}{
+		"id":     payload.ID,
+		"amount": payload.Amount,
+	})
+	expiry.Account = &types.AccountIdentifier{Address: payload.Owner}
+
+	return deferredTransaction(StateChannelExpiryKind+"_", StateChannelExpiryTxn, effect, []*types.Operation{expiry}), nil
+}
+
+// closeStateChannel records the state channel id of owner as closed at the
+// height of block. A close before the channel's expiry rebuilds the block at
+// the expiry, so that it drops the expiry.
+func closeStateChannel(owner, id string, block *types.BlockIdentifier) *types.Error {
+	if block == nil {
+		return nil
+	}
+
+	if err := utils.PutStateChannelHeight(CurrentNetwork, owner, id, utils.StateChannelCloseStage, block.Index); err != nil {
+		return WrapErr(ErrFailed, err)
+	}
+
+	expiry, eErr := utils.GetStateChannelHeight(CurrentNetwork, owner, id, utils.StateChannelExpiryStage)
+	if eErr == badger.ErrKeyNotFound {
+		return nil
+	} else if eErr != nil {
+		return WrapErr(ErrFailed, eErr)
+	}
+
+	if block.Index <= expiry {
+		if iErr := InvalidateBlock(expiry); iErr != nil {
+			return WrapErr(ErrFailed, iErr)
+		}
+	}
+
+	return nil
+}
+
+// stakeWithdrawalCooldown returns the stake_withdrawal_cooldown chain
+// variable, the number of blocks stake stays in cooldown.
+var stakeWithdrawalCooldown = func() (int64, *types.Error) {
+	chainVars, vErr := GetChainVars()
+	if vErr != nil {
+		return 0, WrapErr(ErrFailed, vErr)
+	}
+
+	cooldown, ok := chainVars["stake_withdrawal_cooldown"].(float64)
+	if !ok {
+		return 0, WrapErr(ErrFailed, errors.New("chain variable stake_withdrawal_cooldown is not set"))
+	}
+
+	return int64(cooldown), nil
+}
+
+type stakeTransferCooldownPayload struct {
+	OldAddress  string `json:"old_address"`
+	OldOwner    string `json:"old_owner"`
+	NewAddress  string `json:"new_address"`
+	NewOwner    string `json:"new_owner"`
+	StakeAmount int64  `json:"stake_amount"`
+}
+
+// scheduleStakeTransferCooldown stores the end of the cooldown of the
+// validator whose stake the transfer_validator_stake_v1 txn in block moved,
+// stake_withdrawal_cooldown blocks later.
+func scheduleStakeTransferCooldown(txn map[string]interface{}, block *types.BlockIdentifier) *types.Error {
+	if block == nil {
+		return nil
+	}
+
+	cooldown, cErr := stakeWithdrawalCooldown()
+	if cErr != nil {
+		return cErr
+	}
+
+	return scheduleDeferred(StakeTransferCooldownKind, block.Index+cooldown, fmt.Sprint(txn["hash"]), &stakeTransferCooldownPayload{
+		OldAddress:  fmt.Sprint(txn["old_address"]),
+		OldOwner:    fmt.Sprint(txn["old_owner"]),
+		NewAddress:  fmt.Sprint(txn["new_address"]),
+		NewOwner:    fmt.Sprint(txn["new_owner"]),
+		StakeAmount: utils.JsonNumberToInt64(txn["stake_amount"]),
+	})
+}
+
+// stakeTransferCooldownTransaction reports the end of a transferred
+// validator's cooldown to its old owner. It moves no HNT, since the stake
+// moved with the transfer.
+func stakeTransferCooldownTransaction(effect *utils.DeferredEffect) (*types.Transaction, *types.Error) {
+	var payload stakeTransferCooldownPayload
+	if dErr := decodeDeferredPayload(effect, &payload); dErr != nil {
+		return nil, dErr
+	}
+
+	cooldown, _ := CreateGenericOp(StakeTransferCooldownOp, SuccessStatus, 0, map[string]interface{}{
+		"address":      payload.OldAddress,
+		"new_address":  payload.NewAddress,
+		"new_owner":    payload.NewOwner,
+		"stake_amount": payload.StakeAmount,
+	})
+	cooldown.Account = &types.AccountIdentifier{Address: payload.OldOwner}
+
+	return deferredTransaction(StakeTransferCooldownKind+"_", StakeTransferCooldownTxn, effect, []*types.Operation{cooldown}), nil
+}
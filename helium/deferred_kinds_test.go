@@ -0,0 +1,178 @@
+package helium
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// dueTransactions returns the deferred transactions due at height, failing
+// the test on an error.
+func dueTransactions(t *testing.T, height int64) []*types.Transaction {
+	t.Helper()
+
+	transactions, dErr := DeferredTransactions(height)
+	if dErr != nil {
+		t.Fatalf("DeferredTransactions(%d): %v", height, dErr)
+	}
+	return transactions
+}
+
+func TestHTLCTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		// redeemedAt is the height of the HTLC's redeem, or 0 for none
+		redeemedAt int64
+		reported   bool
+	}{
+		{name: "not redeemed", reported: true},
+		{name: "redeemed before the timelock", redeemedAt: 150, reported: false},
+		{name: "redeemed at the timelock", redeemedAt: 200, reported: false},
+		{name: "refunded after the timelock", redeemedAt: 250, reported: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openTestDB(t)
+
+			if sErr := scheduleHTLCTimeout(map[string]interface{}{
+				"hash":     "create",
+				"address":  "htlc",
+				"payer":    "payer",
+				"payee":    "payee",
+				"amount":   json.Number("5"),
+				"timelock": json.Number("200"),
+			}, &types.BlockIdentifier{Index: 100}); sErr != nil {
+				t.Fatal(sErr)
+			}
+
+			if test.redeemedAt > 0 {
+				if err := utils.PutHTLCTxn(CurrentNetwork, "htlc", utils.HTLCRedeemStage, &utils.HTLCTxnRef{
+					Block:       &types.BlockIdentifier{Index: test.redeemedAt},
+					Transaction: &types.TransactionIdentifier{Hash: "redeem"},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			transactions := dueTransactions(t, 200)
+			transaction, tErr := DeferredTransaction(HTLCTimeoutKind+"_200_create", 200)
+
+			if !test.reported {
+				if len(transactions) != 0 {
+					t.Fatalf("got %d transactions, want none", len(transactions))
+				}
+				if tErr == nil || tErr.Code != ErrNotFound.Code {
+					t.Fatalf("DeferredTransaction: got %v, want ErrNotFound", tErr)
+				}
+				return
+			}
+
+			if tErr != nil {
+				t.Fatal(tErr)
+			}
+			if len(transactions) != 1 || transactions[0].TransactionIdentifier.Hash != transaction.TransactionIdentifier.Hash {
+				t.Fatalf("got %v, want %s", transactions, transaction.TransactionIdentifier.Hash)
+			}
+
+			op := transaction.Operations[0]
+			if op.Type != HTLCTimeoutOp || op.Account.Address != "htlc" || op.Amount != nil {
+				t.Fatalf("got operation %+v", op)
+			}
+			if transaction.RelatedTransactions[0].TransactionIdentifier.Hash != "create" {
+				t.Fatalf("got related transactions %v, want the create", transaction.RelatedTransactions)
+			}
+		})
+	}
+}
+
+func TestHTLCTimeoutAtCreate(t *testing.T) {
+	openTestDB(t)
+
+	// A timelock that has already passed leaves nothing to report
+	if sErr := scheduleHTLCTimeout(map[string]interface{}{
+		"hash":     "create",
+		"address":  "htlc",
+		"timelock": json.Number("100"),
+	}, &types.BlockIdentifier{Index: 100}); sErr != nil {
+		t.Fatal(sErr)
+	}
+
+	if transactions := dueTransactions(t, 100); len(transactions) != 0 {
+		t.Fatalf("got %d transactions, want none", len(transactions))
+	}
+}
+
+func TestStateChannelExpiry(t *testing.T) {
+	openTestDB(t)
+
+	open := map[string]interface{}{
+		"hash":          "open",
+		"owner":         "owner",
+		"id":            "channel",
+		"amount":        json.Number("1000"),
+		"expire_within": json.Number("50"),
+	}
+	if sErr := scheduleStateChannelExpiry(open, &types.BlockIdentifier{Index: 100}); sErr != nil {
+		t.Fatal(sErr)
+	}
+
+	transactions := dueTransactions(t, 150)
+	if len(transactions) != 1 || transactions[0].TransactionIdentifier.Hash != StateChannelExpiryKind+"_150_open" {
+		t.Fatalf("got %v, want the expiry at 150", transactions)
+	}
+	op := transactions[0].Operations[0]
+	if op.Type != StateChannelExpiryOp || op.Account.Address != "owner" || op.Metadata["id"] != "channel" {
+		t.Fatalf("got operation %+v", op)
+	}
+
+	// Closing the channel early drops the expiry, and the stored block
+	// reporting it
+	expiryBlock := &types.Block{BlockIdentifier: &types.BlockIdentifier{Index: 150, Hash: "block150"}}
+	if err := utils.PutBlock(CurrentNetwork, ParserVersion, expiryBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	if cErr := closeStateChannel("owner", "channel", &types.BlockIdentifier{Index: 140}); cErr != nil {
+		t.Fatal(cErr)
+	}
+
+	if transactions := dueTransactions(t, 150); len(transactions) != 0 {
+		t.Fatalf("after the close: got %d transactions, want none", len(transactions))
+	}
+	if _, err := utils.GetBlockByHeight(CurrentNetwork, ParserVersion, 150); err != badger.ErrKeyNotFound {
+		t.Fatalf("stored block at the expiry: got %v, want it dropped", err)
+	}
+}
+
+func TestStakeTransferCooldown(t *testing.T) {
+	openTestDB(t)
+
+	previous := stakeWithdrawalCooldown
+	stakeWithdrawalCooldown = func() (int64, *types.Error) { return 1000, nil }
+	t.Cleanup(func() { stakeWithdrawalCooldown = previous })
+
+	if sErr := scheduleStakeTransferCooldown(map[string]interface{}{
+		"hash":         "transfer",
+		"old_address":  "validator1",
+		"old_owner":    "alice",
+		"new_address":  "validator2",
+		"new_owner":    "bob",
+		"stake_amount": json.Number("1000000"),
+	}, &types.BlockIdentifier{Index: 100}); sErr != nil {
+		t.Fatal(sErr)
+	}
+
+	transactions := dueTransactions(t, 1100)
+	if len(transactions) != 1 || transactions[0].TransactionIdentifier.Hash != StakeTransferCooldownKind+"_1100_transfer" {
+		t.Fatalf("got %v, want the cooldown at 1100", transactions)
+	}
+
+	op := transactions[0].Operations[0]
+	if op.Type != StakeTransferCooldownOp || op.Account.Address != "alice" || op.Metadata["address"] != "validator1" || op.Amount != nil {
+		t.Fatalf("got operation %+v", op)
+	}
+}
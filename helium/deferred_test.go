@@ -0,0 +1,38 @@
+package helium
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+func testTransaction(hash string) *types.Transaction {
+	return &types.Transaction{TransactionIdentifier: &types.TransactionIdentifier{Hash: hash}}
+}
+
+func TestDeferredTxnCounts(t *testing.T) {
+	counts := deferredTxnCounts(nil)
+	want := map[string]int{
+		utils.GhostTxnKind:        0,
+		HTLCTimeoutKind:           0,
+		StateChannelExpiryKind:    0,
+		StakeTransferCooldownKind: 0,
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("no transactions: got %v", counts)
+	}
+
+	counts = deferredTxnCounts([]*types.Transaction{
+		testTransaction(utils.GhostTxnHashPrefix + "10_a"),
+		testTransaction(utils.GhostTxnHashPrefix + "10_b"),
+		testTransaction(HTLCTimeoutKind + "_10_c"),
+		testTransaction("not_deferred"),
+	})
+	want[utils.GhostTxnKind] = 2
+	want[HTLCTimeoutKind] = 1
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("two ghost transactions and an HTLC timeout: got %v", counts)
+	}
+}
@@ -15,6 +15,8 @@
 package helium
 
 import (
+	"fmt"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
@@ -146,3 +148,12 @@ func WrapErr(rErr *types.Error, err error) *types.Error {
 
 	return newErr
 }
+
+// ErrorContext returns the context WrapErr attached to err, prefixed with
+// ": ", or "" if there is none.
+func ErrorContext(err *types.Error) string {
+	if err.Details == nil || err.Details["context"] == nil {
+		return ""
+	}
+	return ": " + fmt.Sprint(err.Details["context"])
+}
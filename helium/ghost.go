@@ -0,0 +1,554 @@
+package helium
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+	"go.uber.org/zap"
+)
+
+// GhostTxnManifest is the file in each network's ghost transaction directory
+// listing the sha256 of every ghost transaction file, in `sha256sum` format.
+const GhostTxnManifest = "MANIFEST"
+
+// GhostTxnRecord is one entry of a ghost transaction file: an
+// unstake_validator_v1 as exported from blockchain-etl, with the transaction
+// itself JSON-encoded in Fields.
+type GhostTxnRecord struct {
+	Block  int64  `json:"Block"`
+	Hash   string `json:"Hash"`
+	Type   string `json:"Type"`
+	Fields string `json:"Fields"`
+	Time   string `json:"Time,omitempty"`
+}
+
+// GhostTxnFileError locates a problem in a ghost transaction file.
+type GhostTxnFileError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *GhostTxnFileError) Error() string {
+	if e.Line == 0 {
+		return e.File + ": " + e.Err.Error()
+	}
+	return e.File + ":" + fmt.Sprint(e.Line) + ": " + e.Err.Error()
+}
+
+func (e *GhostTxnFileError) Unwrap() error {
+	return e.Err
+}
+
+// UnstakeFields are the fields of an unstake_validator_v1 kept as the
+// metadata of its ghost transaction.
+func UnstakeFields(txn map[string]interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for _, field := range []string{"address", "fee", "hash", "owner", "owner_signature", "stake_amount", "stake_release_height"} {
+		if txn[field] != nil {
+			fields[field] = txn[field]
+		}
+	}
+	return fields
+}
+
+// RebuildLegacyGhostTxn rebuilds a ghost transaction stored before the
+// deferred effect store from the unstake it recorded. Those credited the
+// release without debiting the owner's cooldown sub-account, and those loaded
+// from ghost transaction files credited the validator instead of the owner.
+// The unstake's fields are the ghost transaction's metadata, or for ones
+// stored while processing the unstake, the metadata of its only operation.
+func RebuildLegacyGhostTxn(key *utils.GhostTxnKey, legacy *utils.GhostTxnMetadata) (*utils.GhostTxnMetadata, error) {
+	fields := legacy.Metadata
+	if fields == nil && len(legacy.Operations) == 1 {
+		fields = legacy.Operations[0].Metadata
+	}
+
+	owner, _ := fields["owner"].(string)
+	stake, _ := fields["stake_amount"].(float64)
+	if owner == "" || stake <= 0 {
+		return nil, errors.New("ghost transaction " + key.Transaction.Hash + " does not record its unstake's owner and stake_amount")
+	}
+
+	releaseOps, rErr := UnstakeReleaseOps(owner, int64(stake), fields)
+	if rErr != nil {
+		return nil, errors.New(rErr.Message + ErrorContext(rErr))
+	}
+
+	return &utils.GhostTxnMetadata{
+		Operations: releaseOps,
+		Metadata:   UnstakeFields(fields),
+		RelatedTransactions: []*types.RelatedTransaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: key.Transaction.Hash,
+				},
+				Direction: types.Backward,
+			},
+		},
+	}, nil
+}
+
+// GhostTxnDir is the directory holding the ghost transaction files of
+// network under root, e.g. ghost-transactions/mainnet.
+func GhostTxnDir(root string, network *types.NetworkIdentifier) string {
+	return filepath.Join(root, strings.ToLower(network.Network))
+}
+
+// Unstake decodes and validates the unstake transaction carried by record.
+func (record *GhostTxnRecord) Unstake() (*UnstakeTransaction, map[string]interface{}, error) {
+	if record.Hash == "" {
+		return nil, nil, errors.New("missing Hash")
+	}
+
+	if record.Type != UnstakeValidatorV1Txn {
+		return nil, nil, errors.New("unsupported Type " + fmt.Sprintf("%q", record.Type) + ", expected " + UnstakeValidatorV1Txn)
+	}
+
+	var unstake UnstakeTransaction
+	if err := json.Unmarshal([]byte(record.Fields), &unstake); err != nil {
+		return nil, nil, errors.New("invalid Fields: " + err.Error())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(record.Fields))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, nil, errors.New("invalid Fields: " + err.Error())
+	}
+
+	switch {
+	case unstake.Hash != record.Hash:
+		return nil, nil, errors.New("Fields.hash " + fmt.Sprintf("%q", unstake.Hash) + " does not match Hash " + fmt.Sprintf("%q", record.Hash))
+	case unstake.Owner == "":
+		return nil, nil, errors.New("missing Fields.owner")
+	case unstake.StakeAmount <= 0:
+		return nil, nil, errors.New("Fields.stake_amount must be positive, got " + fmt.Sprint(unstake.StakeAmount))
+	case record.Block <= 0:
+		return nil, nil, errors.New("Block must be positive, got " + fmt.Sprint(record.Block))
+	case unstake.StakeReleaseHeight <= record.Block:
+		return nil, nil, errors.New("Fields.stake_release_height " + fmt.Sprint(unstake.StakeReleaseHeight) +
+			" is not above Block " + fmt.Sprint(record.Block))
+	}
+
+	return &unstake, fields, nil
+}
+
+// lineAt returns the line of the first non-space byte at or after offset.
+func lineAt(content []byte, offset int64) int {
+	for offset < int64(len(content)) && bytes.IndexByte([]byte(" \t\r\n,"), content[offset]) >= 0 {
+		offset++
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// ParseGhostTxnFile reads and validates every record of a ghost transaction
+// file, a JSON array of GhostTxnRecord. Errors carry the file and line.
+func ParseGhostTxnFile(path string) ([]*GhostTxnRecord, error) {
+	content, rerr := ioutil.ReadFile(path)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	fileErr := func(offset int64, err error) error {
+		return &GhostTxnFileError{File: path, Line: lineAt(content, offset), Err: err}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	if token, terr := decoder.Token(); terr != nil || token != json.Delim('[') {
+		return nil, fileErr(0, errors.New("expected a JSON array of ghost transactions"))
+	}
+
+	var records []*GhostTxnRecord
+	seen := map[string]int{}
+
+	for decoder.More() {
+		start := decoder.InputOffset()
+
+		var record GhostTxnRecord
+		if derr := decoder.Decode(&record); derr != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(derr, &syntaxErr) {
+				return nil, fileErr(syntaxErr.Offset-1, derr)
+			}
+			return nil, fileErr(start, derr)
+		}
+
+		if _, _, verr := record.Unstake(); verr != nil {
+			return nil, fileErr(start, verr)
+		}
+
+		if line, ok := seen[record.Hash]; ok {
+			return nil, fileErr(start, errors.New("duplicate Hash "+record.Hash+", first seen on line "+fmt.Sprint(line)))
+		}
+		seen[record.Hash] = lineAt(content, start)
+
+		records = append(records, &record)
+	}
+
+	if _, terr := decoder.Token(); terr != nil {
+		return nil, fileErr(decoder.InputOffset(), terr)
+	}
+
+	return records, nil
+}
+
+// ReadGhostTxnManifest returns the sha256 of each file listed in the
+// manifest of dir.
+func ReadGhostTxnManifest(dir string) (map[string]string, error) {
+	path := filepath.Join(dir, GhostTxnManifest)
+
+	manifestFile, oerr := os.Open(path)
+	if oerr != nil {
+		return nil, oerr
+	}
+	defer manifestFile.Close()
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(manifestFile)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.Fields(text)
+		if len(parts) != 2 || len(parts[0]) != sha256.Size*2 {
+			return nil, &GhostTxnFileError{File: path, Line: line, Err: errors.New("expected `<sha256>  <file>`")}
+		}
+
+		name := strings.TrimPrefix(parts[1], "*")
+		if _, ok := checksums[name]; ok {
+			return nil, &GhostTxnFileError{File: path, Line: line, Err: errors.New("duplicate entry for " + name)}
+		}
+		checksums[name] = strings.ToLower(parts[0])
+	}
+	if serr := scanner.Err(); serr != nil {
+		return nil, serr
+	}
+
+	return checksums, nil
+}
+
+// GhostTxnFiles returns the ghost transaction files in dir after checking
+// them against its manifest: every file must be listed with a matching
+// checksum, and every listed file must exist.
+func GhostTxnFiles(dir string) ([]string, error) {
+	checksums, merr := ReadGhostTxnManifest(dir)
+	if merr != nil {
+		return nil, merr
+	}
+
+	entries, rerr := ioutil.ReadDir(dir)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		expected, ok := checksums[entry.Name()]
+		if !ok {
+			return nil, &GhostTxnFileError{File: path, Err: errors.New("not listed in " + GhostTxnManifest)}
+		}
+		delete(checksums, entry.Name())
+
+		content, ferr := ioutil.ReadFile(path)
+		if ferr != nil {
+			return nil, ferr
+		}
+
+		sum := sha256.Sum256(content)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			return nil, &GhostTxnFileError{
+				File: path,
+				Err:  errors.New("sha256 " + actual + " does not match " + GhostTxnManifest + " (" + expected + ")"),
+			}
+		}
+
+		files = append(files, path)
+	}
+
+	if len(checksums) > 0 {
+		var missing []string
+		for name := range checksums {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+
+		return nil, &GhostTxnFileError{
+			File: filepath.Join(dir, GhostTxnManifest),
+			Err:  errors.New("lists missing files " + strings.Join(missing, ", ")),
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// GhostTxnFromRecord returns the key and stored form of the ghost
+// transaction releasing the stake of the unstake in record.
+func GhostTxnFromRecord(network *types.NetworkIdentifier, record *GhostTxnRecord) (*utils.GhostTxnKey, *utils.GhostTxnMetadata, error) {
+	unstake, fields, verr := record.Unstake()
+	if verr != nil {
+		return nil, nil, verr
+	}
+
+	releaseOps, uErr := UnstakeReleaseOps(unstake.Owner, unstake.StakeAmount, fields)
+	if uErr != nil {
+		return nil, nil, errors.New(uErr.Message)
+	}
+
+	key := &utils.GhostTxnKey{
+		Network: network,
+		Block: &types.BlockIdentifier{
+			Index: unstake.StakeReleaseHeight,
+		},
+		Transaction: &types.TransactionIdentifier{
+			Hash: unstake.Hash,
+		},
+	}
+
+	return key, &utils.GhostTxnMetadata{
+		Operations:   releaseOps,
+		Metadata:     UnstakeFields(fields),
+		UnstakeBlock: record.Block,
+	}, nil
+}
+
+// LoadGhostTxns stores the ghost transactions listed in the files of
+// network's directory under root. Every file is validated before anything is
+// stored, so a bad file leaves the store untouched. A network without a
+// directory has no ghost transactions.
+func LoadGhostTxns(root string, network *types.NetworkIdentifier) error {
+	dir := GhostTxnDir(root, network)
+	if _, serr := os.Stat(dir); os.IsNotExist(serr) {
+		zap.S().Info("No ghost transaction directory " + dir + ", skipping")
+		return nil
+	}
+
+	files, ferr := GhostTxnFiles(dir)
+	if ferr != nil {
+		return ferr
+	}
+
+	recordsByFile := map[string][]*GhostTxnRecord{}
+	for _, file := range files {
+		records, perr := ParseGhostTxnFile(file)
+		if perr != nil {
+			return perr
+		}
+		recordsByFile[file] = records
+	}
+
+	for _, file := range files {
+		created := 0
+		for _, record := range recordsByFile[file] {
+			key, metadata, gerr := GhostTxnFromRecord(network, record)
+			if gerr != nil {
+				return &GhostTxnFileError{File: file, Err: gerr}
+			}
+
+			cerr := utils.CreateGhostTxn(key, metadata)
+			if cerr != nil && cerr != badger.ErrBannedKey {
+				return cerr
+			}
+
+			if cerr == nil {
+				created++
+				if ierr := InvalidateBlock(key.Block.Index); ierr != nil {
+					return ierr
+				}
+			}
+		}
+
+		zap.S().Info("Loaded " + fmt.Sprint(len(recordsByFile[file])) + " ghost txns (" + fmt.Sprint(created) + " new) from file " + file)
+	}
+
+	return nil
+}
+
+// GhostTxnRecordFromStored turns a stored ghost transaction back into the
+// record it would be loaded from. The unstake's block comes from the store or,
+// for ghost transactions stored without it, from the search index.
+func GhostTxnRecordFromStored(stored *utils.StoredGhostTxn) (*GhostTxnRecord, error) {
+	hash := stored.Key.Transaction.Hash
+	if stored.Metadata.Metadata["owner"] == nil || stored.Metadata.Metadata["stake_amount"] == nil {
+		return nil, errors.New(hash + ": no unstake fields stored")
+	}
+
+	unstakeBlock := stored.Metadata.UnstakeBlock
+	if unstakeBlock == 0 {
+		entries, serr := utils.ScanSearchEntries(stored.Key.Network, ParserVersion, utils.SearchByTransaction, hash)
+		if serr != nil {
+			return nil, serr
+		}
+		if len(entries) == 0 {
+			return nil, errors.New(hash + ": block of the unstake is unknown")
+		}
+		unstakeBlock = entries[0].Block.Index
+	}
+
+	fields := map[string]interface{}{"type": UnstakeValidatorV1Txn}
+	for field, value := range stored.Metadata.Metadata {
+		fields[field] = value
+	}
+
+	fieldsBytes, merr := json.Marshal(fields)
+	if merr != nil {
+		return nil, merr
+	}
+
+	record := &GhostTxnRecord{
+		Block:  unstakeBlock,
+		Hash:   hash,
+		Type:   UnstakeValidatorV1Txn,
+		Fields: string(fieldsBytes),
+	}
+
+	if _, _, verr := record.Unstake(); verr != nil {
+		return nil, errors.New(hash + ": " + verr.Error())
+	}
+
+	return record, nil
+}
+
+// WriteGhostTxnFile writes records in the format ParseGhostTxnFile reads,
+// one record per line.
+func WriteGhostTxnFile(w io.Writer, records []*GhostTxnRecord) error {
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		recordBytes, merr := json.Marshal(record)
+		if merr != nil {
+			return merr
+		}
+
+		separator := ",\n"
+		if i == len(records)-1 {
+			separator = "\n"
+		}
+
+		if _, err := w.Write(append(recordBytes, separator...)); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// VerifyGhostTxn checks a stored ghost transaction against the node: the
+// unstake it was created from, and the validator's state just before the
+// release. It returns every mismatch found.
+func VerifyGhostTxn(stored *utils.StoredGhostTxn) ([]string, *types.Error) {
+	var problems []string
+
+	hash := stored.Key.Transaction.Hash
+	releaseHeight := stored.Key.Block.Index
+	fields := stored.Metadata.Metadata
+
+	owner := fmt.Sprint(fields["owner"])
+	address := fmt.Sprint(fields["address"])
+	stake := jsonInt64(fields["stake_amount"])
+
+	if fields["owner"] == nil || fields["address"] == nil || fields["stake_amount"] == nil {
+		problems = append(problems, "no unstake fields stored")
+	}
+
+	released := int64(0)
+	for _, op := range stored.Metadata.Operations {
+		if op.Account != nil && op.Account.SubAccount == nil && op.Account.Address == owner && op.Amount != nil {
+			value, ok := new(big.Int).SetString(op.Amount.Value, 10)
+			if ok {
+				released += value.Int64()
+			}
+		}
+	}
+	if released != stake {
+		problems = append(problems, "release credits "+fmt.Sprint(released)+" to "+owner+", expected "+fmt.Sprint(stake))
+	}
+
+	unstake, tErr := getRawTransaction(hash)
+	if tErr != nil && tErr.Code != ErrNotFound.Code {
+		return nil, tErr
+	}
+
+	if tErr != nil {
+		problems = append(problems, "unstake "+hash+" not found on the node")
+	} else {
+		switch {
+		case unstake["type"] != UnstakeValidatorV1Txn:
+			problems = append(problems, "node reports "+hash+" as "+fmt.Sprint(unstake["type"]))
+		case fmt.Sprint(unstake["owner"]) != owner:
+			problems = append(problems, "node reports owner "+fmt.Sprint(unstake["owner"])+", stored "+owner)
+		case jsonInt64(unstake["stake_amount"]) != stake:
+			problems = append(problems, "node reports stake_amount "+fmt.Sprint(unstake["stake_amount"])+", stored "+fmt.Sprint(stake))
+		case jsonInt64(unstake["stake_release_height"]) != releaseHeight:
+			problems = append(problems, "node reports stake_release_height "+fmt.Sprint(unstake["stake_release_height"])+", stored "+fmt.Sprint(releaseHeight))
+		}
+	}
+
+	validator, vErr := GetValidator(address, releaseHeight-1)
+	if vErr != nil && vErr.Code != ErrNotFound.Code {
+		return nil, vErr
+	}
+
+	if vErr != nil {
+		problems = append(problems, "validator "+address+" not found at block "+fmt.Sprint(releaseHeight-1))
+	} else {
+		if validator["owner"] != nil && fmt.Sprint(validator["owner"]) != owner {
+			problems = append(problems, "validator owner is "+fmt.Sprint(validator["owner"])+" at block "+fmt.Sprint(releaseHeight-1)+", stored "+owner)
+		}
+		if validator["stake"] != nil && jsonInt64(validator["stake"]) != stake {
+			problems = append(problems, "validator stake is "+fmt.Sprint(validator["stake"])+" at block "+fmt.Sprint(releaseHeight-1)+", stored "+fmt.Sprint(stake))
+		}
+		if validator["stake_release_height"] != nil && jsonInt64(validator["stake_release_height"]) != releaseHeight {
+			problems = append(problems, "validator stake_release_height is "+fmt.Sprint(validator["stake_release_height"])+", stored "+fmt.Sprint(releaseHeight))
+		}
+	}
+
+	return problems, nil
+}
+
+// jsonInt64 reads an integer decoded either with json.Number (from the node)
+// or as float64 (from the store).
+func jsonInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,104 @@
+package helium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+	"go.uber.org/zap"
+)
+
+// ghostBackfillProgressInterval is how many blocks BackfillGhostTxns scans
+// between progress log lines.
+const ghostBackfillProgressInterval = 1000
+
+// GhostBackfillReport summarises a BackfillGhostTxns run.
+type GhostBackfillReport struct {
+	From     int64
+	To       int64
+	Unstakes int
+	Added    []string
+	Existing int
+}
+
+func (r *GhostBackfillReport) String() string {
+	return "scanned blocks " + fmt.Sprint(r.From) + " to " + fmt.Sprint(r.To) +
+		": " + fmt.Sprint(r.Unstakes) + " unstakes, " + fmt.Sprint(len(r.Added)) + " ghost txns added, " +
+		fmt.Sprint(r.Existing) + " already stored"
+}
+
+// BackfillGhostTxns scans blocks from through to (inclusive) on the node and
+// stores the ghost transaction of every unstake_validator_v1 found, so that
+// unstakes from before this instance started processing blocks still release
+// their stake.
+func BackfillGhostTxns(ctx context.Context, from int64, to int64) (*GhostBackfillReport, error) {
+	if from < 1 || to < from {
+		return nil, errors.New("invalid ghost backfill range " + fmt.Sprint(from) + " to " + fmt.Sprint(to))
+	}
+
+	report := &GhostBackfillReport{
+		From: from,
+		To:   to,
+	}
+
+	for height := from; height <= to; height++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		blockHeight := height
+		block, bErr := getRawBlock(&types.PartialBlockIdentifier{Index: &blockHeight})
+		if bErr != nil {
+			return report, errors.New("block " + fmt.Sprint(height) + ": " + bErr.Message + ErrorContext(bErr))
+		}
+
+		for _, txn := range block.Transactions {
+			// block_get lists each transaction's type, so only unstakes are fetched
+			if txn["type"] != nil && txn["type"] != UnstakeValidatorV1Txn {
+				continue
+			}
+
+			hash := fmt.Sprint(txn["hash"])
+			result, tErr := getRawTransaction(hash)
+			if tErr != nil {
+				return report, errors.New("transaction " + hash + ": " + tErr.Message + ErrorContext(tErr))
+			}
+
+			if result["type"] != UnstakeValidatorV1Txn {
+				continue
+			}
+			report.Unstakes++
+
+			releaseHeight := utils.JsonNumberToInt64(result["stake_release_height"])
+			created, gErr := CreateUnstakeGhostTxn(
+				fmt.Sprint(result["owner"]),
+				utils.JsonNumberToInt64(result["stake_amount"]),
+				releaseHeight,
+				height,
+				result,
+			)
+			if gErr != nil {
+				return report, errors.New("transaction " + hash + ": " + gErr.Message + ErrorContext(gErr))
+			}
+
+			if created {
+				ghostHash := utils.GhostTxnHash(&utils.GhostTxnKey{
+					Block:       &types.BlockIdentifier{Index: releaseHeight},
+					Transaction: &types.TransactionIdentifier{Hash: hash},
+				})
+				zap.S().Info("Ghost backfill added " + ghostHash + " for unstake at block " + fmt.Sprint(height))
+				report.Added = append(report.Added, ghostHash)
+			} else {
+				report.Existing++
+			}
+		}
+
+		if (height-from+1)%ghostBackfillProgressInterval == 0 {
+			zap.S().Info("Ghost backfill at block " + fmt.Sprint(height) + " of " + fmt.Sprint(to))
+		}
+	}
+
+	return report, nil
+}
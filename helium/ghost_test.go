@@ -0,0 +1,98 @@
+package helium
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// TestMigrateBaselineGhostTxns migrates ghost transactions as the first
+// releases stored them, which released the stake with a single credit.
+func TestMigrateBaselineGhostTxns(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		payload string
+	}{
+		{
+			// Stored while processing the unstake: the unstake's fields are
+			// the credit's metadata
+			name:   "processed",
+			source: "processed",
+			payload: `{"operations":[{"operation_identifier":{"index":0},"type":"unstake_validator_op","status":"success",` +
+				`"account":{"address":"owner"},"amount":{"value":"1000000000000","currency":{"symbol":"HNT","decimals":8}},` +
+				`"metadata":` + baselineUnstake("processed") + `}],"metadata":null}`,
+		},
+		{
+			// Loaded from a ghost transaction file: the credit went to the
+			// validator address
+			name:   "loaded",
+			source: "loaded",
+			payload: `{"operations":[{"operation_identifier":{"index":0},"type":"unstake_validator_op","status":"success",` +
+				`"account":{"address":"validator"},"amount":{"value":"1000000000000","currency":{"symbol":"HNT","decimals":8}},` +
+				`"metadata":` + baselineUnstake("loaded") + `}],"metadata":` + baselineUnstake("loaded") + `}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openTestDB(t)
+
+			var legacyKey []byte
+			for _, field := range []interface{}{
+				CurrentNetwork,
+				&types.BlockIdentifier{Index: 500},
+				&types.TransactionIdentifier{Hash: test.source},
+			} {
+				fieldBytes, _ := json.Marshal(field)
+				legacyKey = append(legacyKey, fieldBytes...)
+			}
+			if err := utils.DB.Update(func(txn *badger.Txn) error {
+				return txn.Set(legacyKey, []byte(test.payload))
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := utils.Migrate(RebuildLegacyGhostTxn); err != nil {
+				t.Fatal(err)
+			}
+
+			transactions, dErr := DeferredTransactions(500)
+			if dErr != nil {
+				t.Fatal(dErr)
+			}
+			if len(transactions) != 1 {
+				t.Fatalf("got %d transactions, want 1", len(transactions))
+			}
+
+			ops := transactions[0].Operations
+			if len(ops) != 2 {
+				t.Fatalf("got %d operations, want the cooldown debit and the credit", len(ops))
+			}
+			if ops[0].Account.Address != "owner" || ops[0].Account.SubAccount == nil ||
+				ops[0].Account.SubAccount.Address != CooldownSubAccount || ops[0].Amount.Value != "-1000000000000" {
+				t.Fatalf("got cooldown operation %+v", ops[0])
+			}
+			if ops[1].Account.Address != "owner" || ops[1].Account.SubAccount != nil || ops[1].Amount.Value != "1000000000000" {
+				t.Fatalf("got release operation %+v", ops[1])
+			}
+		})
+	}
+}
+
+// baselineUnstake is the JSON of the fields of the unstake hash.
+func baselineUnstake(hash string) string {
+	fieldBytes, _ := json.Marshal(map[string]interface{}{
+		"type":                 UnstakeValidatorV1Txn,
+		"hash":                 hash,
+		"address":              "validator",
+		"owner":                "owner",
+		"stake_amount":         1000000000000,
+		"stake_release_height": 500,
+		"fee":                  35000,
+	})
+	return string(fieldBytes)
+}
@@ -0,0 +1,105 @@
+package helium
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+	"github.com/ybbus/jsonrpc"
+)
+
+func TestHTLCBalanceAt(t *testing.T) {
+	receipt := &HTLCReceipt{Balance: 500, RedeemedAt: 200}
+
+	tests := []struct {
+		name   string
+		height int64
+		want   int64
+	}{
+		{"before create", 99, 0},
+		{"at create", 100, 500},
+		{"between create and redeem", 150, 500},
+		{"at redeem", 200, 0},
+		{"after redeem", 250, 0},
+	}
+
+	for _, test := range tests {
+		if got := htlcBalanceAt(receipt, 100, test.height); got != test.want {
+			t.Errorf("%s: balance at %d = %d, want %d", test.name, test.height, got, test.want)
+		}
+	}
+
+	unredeemed := &HTLCReceipt{Balance: 500}
+	if got := htlcBalanceAt(unredeemed, 100, 1000000); got != 500 {
+		t.Errorf("unredeemed: balance = %d, want 500", got)
+	}
+}
+
+func indexHTLCCreate(t *testing.T, address string, height int64) {
+	t.Helper()
+
+	if err := utils.PutHTLCTxn(CurrentNetwork, address, utils.HTLCCreateStage, &utils.HTLCTxnRef{
+		Block:       &types.BlockIdentifier{Index: height, Hash: "block"},
+		Transaction: &types.TransactionIdentifier{Hash: "create"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetHTLCBalance(t *testing.T) {
+	openTestDB(t)
+	node := startTestNode(t, map[string]nodeHandler{
+		"htlc_get": func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			if params["address"] == "wallet" {
+				return nodeNotFound(params)
+			}
+			return map[string]interface{}{"balance": 500, "payer": "payer", "redeemed_at": 0, "timelock": 300}, nil
+		},
+	})
+
+	// A wallet with a ledger account is never looked up as an HTLC
+	if _, hErr := GetHTLCBalance("wallet", &GetBalanceResponse{Balance: 10}, 150); hErr == nil || hErr.Code != ErrNotFound.Code {
+		t.Fatalf("funded wallet: got %v, want ErrNotFound", hErr)
+	}
+	if calls := node.callCount("htlc_get"); calls != 0 {
+		t.Fatalf("funded wallet: htlc_get called %d times", calls)
+	}
+
+	// An empty account is checked with the node
+	if _, hErr := GetHTLCBalance("wallet", nil, 150); hErr == nil || hErr.Code != ErrNotFound.Code {
+		t.Fatalf("empty wallet: got %v, want ErrNotFound", hErr)
+	}
+	if calls := node.callCount("htlc_get"); calls != 1 {
+		t.Fatalf("empty wallet: htlc_get called %d times, want 1", calls)
+	}
+
+	// An HTLC whose create has not been indexed is not guessed at
+	if _, hErr := GetHTLCBalance("htlc1", nil, 150); hErr == nil || hErr.Code != ErrFailed.Code {
+		t.Fatalf("unindexed htlc: got %v, want ErrFailed", hErr)
+	}
+
+	indexHTLCCreate(t, "htlc1", 100)
+
+	for height, want := range map[int64]string{99: "0", 150: "500"} {
+		balances, hErr := GetHTLCBalance("htlc1", nil, height)
+		if hErr != nil {
+			t.Fatal(hErr)
+		}
+		if balances[0].Value != want {
+			t.Fatalf("indexed htlc at %d: got %s, want %s", height, balances[0].Value, want)
+		}
+	}
+}
+
+func TestHTLCCreateHeight(t *testing.T) {
+	openTestDB(t)
+
+	if _, indexed, err := htlcCreateHeight("unknown"); err != nil || indexed {
+		t.Fatalf("unknown address: got %v, %v, want not indexed", indexed, err)
+	}
+
+	indexHTLCCreate(t, "htlc1", 100)
+	if height, indexed, err := htlcCreateHeight("htlc1"); err != nil || !indexed || height != 100 {
+		t.Fatalf("from HTLC index: got %d, %v, %v, want 100", height, indexed, err)
+	}
+}
@@ -0,0 +1,215 @@
+package helium
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+	"go.uber.org/zap"
+)
+
+// Indexer follows the node tip and builds every new block into the block
+// store ahead of any /block request for it.
+type Indexer struct {
+	pollInterval time.Duration
+	backfill     bool
+
+	mu            sync.RWMutex
+	startHeight   int64
+	indexedHeight int64
+	tipHeight     int64
+	lastError     string
+}
+
+// NewIndexer creates an Indexer. With backfill set, an indexer without any
+// stored progress starts from the last blessed snapshot instead of the tip.
+func NewIndexer(pollInterval time.Duration, backfill bool) *Indexer {
+	return &Indexer{
+		pollInterval: pollInterval,
+		backfill:     backfill,
+	}
+}
+
+// Run indexes blocks until ctx is cancelled.
+func (i *Indexer) Run(ctx context.Context) {
+	if err := i.init(ctx); err != nil {
+		return
+	}
+
+	zap.S().Info("Indexer starting after block " + fmt.Sprint(i.IndexedHeight()))
+
+	for {
+		tipHeight, chErr := GetCurrentHeight()
+		if chErr != nil {
+			i.setError(chErr)
+		} else {
+			i.mu.Lock()
+			i.tipHeight = *tipHeight
+			i.mu.Unlock()
+
+			// Blocks near the tip are not stored yet (see cacheBlock), so
+			// the indexer trails it by IrreversibleDepth
+			i.catchUp(ctx, *tipHeight-IrreversibleDepth)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(i.pollInterval):
+		}
+	}
+}
+
+func (i *Indexer) init(ctx context.Context) error {
+	indexedHeight, err := utils.GetIndexedHeight(CurrentNetwork, ParserVersion)
+	for err != nil && err != badger.ErrKeyNotFound {
+		zap.S().Error("Indexer unable to read its progress: " + err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(i.pollInterval):
+		}
+		indexedHeight, err = utils.GetIndexedHeight(CurrentNetwork, ParserVersion)
+	}
+
+	if err == badger.ErrKeyNotFound {
+		eventHead, ehErr := utils.GetBlockEventHead(CurrentNetwork)
+		if ehErr != nil && ehErr != badger.ErrKeyNotFound {
+			zap.S().Warn("Indexer unable to read the block event log: " + ehErr.Error())
+		}
+
+		if i.backfill {
+			indexedHeight = *LBS - 1
+		} else if ehErr == nil {
+			// Carry on from the event log (e.g. after a ParserVersion bump)
+			// so that it has no gaps
+			indexedHeight = eventHead
+		} else {
+			tipHeight, chErr := GetCurrentHeight()
+			for chErr != nil {
+				i.setError(chErr)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(i.pollInterval):
+				}
+				tipHeight, chErr = GetCurrentHeight()
+			}
+			indexedHeight = *tipHeight - IrreversibleDepth - 1
+		}
+	}
+
+	i.mu.Lock()
+	i.startHeight = indexedHeight + 1
+	i.indexedHeight = indexedHeight
+	i.mu.Unlock()
+
+	return nil
+}
+
+func (i *Indexer) catchUp(ctx context.Context, tipHeight int64) {
+	for height := i.IndexedHeight() + 1; height <= tipHeight; height++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		blockHeight := height
+		block, bErr := GetBlock(&types.PartialBlockIdentifier{Index: &blockHeight})
+		if bErr != nil {
+			i.setError(bErr)
+			return
+		}
+
+		if eErr := recordBlockEvent(block.BlockIdentifier, block.ParentBlockIdentifier); eErr != nil {
+			i.setError(eErr)
+			return
+		}
+
+		if err := utils.PutIndexedHeight(CurrentNetwork, ParserVersion, height); err != nil {
+			zap.S().Warn("Indexer unable to store its progress: " + err.Error())
+		}
+
+		i.mu.Lock()
+		i.indexedHeight = height
+		i.lastError = ""
+		i.mu.Unlock()
+	}
+}
+
+// recordBlockEvent adds block to the block event log. If the log holds a
+// different block at the parent's height, the node has replaced it, so the
+// old block is invalidated and the new parent is recorded first (which
+// removes the old one from the log).
+func recordBlockEvent(block *types.BlockIdentifier, parent *types.BlockIdentifier) *types.Error {
+	if parent != nil && parent.Index < block.Index {
+		hash, err := utils.GetBlockEventHash(CurrentNetwork, parent.Index)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return WrapErr(ErrFailed, err)
+		}
+
+		if err == nil && hash != parent.Hash {
+			// The stored block and search entries at the parent's height
+			// belong to the block that was reorganised away
+			if iErr := InvalidateBlock(parent.Index); iErr != nil {
+				return WrapErr(ErrFailed, iErr)
+			}
+
+			parentHeader, pErr := GetBlockHeader(&types.PartialBlockIdentifier{Hash: &parent.Hash})
+			if pErr != nil {
+				return pErr
+			}
+
+			if rErr := recordBlockEvent(parentHeader.BlockIdentifier(), &types.BlockIdentifier{
+				Index: parentHeader.Height - 1,
+				Hash:  parentHeader.PrevHash,
+			}); rErr != nil {
+				return rErr
+			}
+		}
+	}
+
+	if err := utils.AddBlockEvent(CurrentNetwork, block); err != nil {
+		return WrapErr(ErrFailed, err)
+	}
+
+	return nil
+}
+
+func (i *Indexer) setError(err *types.Error) {
+	message := err.Message
+	if err.Details != nil {
+		message += ": " + fmt.Sprint(err.Details["context"])
+	}
+
+	zap.S().Warn("Indexer: " + message)
+
+	i.mu.Lock()
+	i.lastError = message
+	i.mu.Unlock()
+}
+
+// IndexedHeight is the last height built into the block store.
+func (i *Indexer) IndexedHeight() int64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.indexedHeight
+}
+
+// Stage describes the indexer's progress for /network/status.
+func (i *Indexer) Stage() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	stage := "indexed " + fmt.Sprint(i.indexedHeight) + " of " + fmt.Sprint(i.tipHeight) +
+		" (from " + fmt.Sprint(i.startHeight) + ")"
+
+	if i.lastError != "" {
+		stage += ", last error: " + i.lastError
+	}
+
+	return stage
+}
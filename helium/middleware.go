@@ -2,14 +2,18 @@ package helium
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
 	"github.com/helium/rosetta-helium/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/ybbus/jsonrpc"
@@ -18,6 +22,10 @@ import (
 
 var (
 	NodeClient = jsonrpc.NewClient("http://localhost:4467")
+
+	// TransactionWorkers is the number of transactions
+	// GetBlock fetches and decodes concurrently
+	TransactionWorkers = 8
 )
 
 type MetadataOptions struct {
@@ -56,14 +64,16 @@ type GetBalanceRequest struct {
 }
 
 type GetBalanceResponse struct {
-	Address    string `json:"address"`
-	Balance    int64  `json:"balance"`
-	Block      int64  `json:"block"`
-	DCBalance  int64  `json:"dc_balance"`
-	DCNonce    int64  `json:"dc_nonce"`
-	Nonce      int64  `json:"nonce"`
-	SecBalance int64  `json:"sec_balance"`
-	SecNonce   int64  `json:"sec_nonce"`
+	Address         string `json:"address"`
+	Balance         int64  `json:"balance"`
+	Block           int64  `json:"block"`
+	CooldownBalance int64  `json:"cooldown_balance"`
+	DCBalance       int64  `json:"dc_balance"`
+	DCNonce         int64  `json:"dc_nonce"`
+	Nonce           int64  `json:"nonce"`
+	SecBalance      int64  `json:"sec_balance"`
+	SecNonce        int64  `json:"sec_nonce"`
+	StakedBalance   int64  `json:"staked_balance"`
 }
 
 type GetGatewayOwnerResponse struct {
@@ -80,7 +90,7 @@ func GetCurrentHeight() (*int64, *types.Error) {
 	return &result, nil
 }
 
-func GetBlock(blockIdentifier *types.PartialBlockIdentifier) (*types.Block, *types.Error) {
+func getRawBlock(blockIdentifier *types.PartialBlockIdentifier) (*Block, *types.Error) {
 	type request struct {
 		Height int64  `json:"height,omitempty"`
 		Hash   string `json:"hash,omitempty"`
@@ -89,6 +99,10 @@ func GetBlock(blockIdentifier *types.PartialBlockIdentifier) (*types.Block, *typ
 	var result Block
 	var req request
 
+	if blockIdentifier == nil || (blockIdentifier.Index == nil && blockIdentifier.Hash == nil) {
+		return nil, WrapErr(ErrInvalidParameter, errors.New("block identifier requires an Index or a Hash"))
+	}
+
 	if blockIdentifier.Index != nil && blockIdentifier.Hash != nil {
 		req = request{
 			Height: *blockIdentifier.Index,
@@ -114,52 +128,212 @@ func GetBlock(blockIdentifier *types.PartialBlockIdentifier) (*types.Block, *typ
 		)
 	}
 
-	var processedTxs []*types.Transaction
-	for _, tx := range result.Transactions {
-		ptx, txErr := GetTransaction(fmt.Sprint(tx["hash"]), nil)
-		if txErr != nil {
-			return nil, txErr
-		}
+	if result.Hash == "" {
+		return nil, WrapErr(ErrNotFound, errors.New("block cannot be found"))
+	}
+
+	return &result, nil
+}
 
-		processedTxs = append(processedTxs, ptx)
+// GetBlockHeader fetches a block's height, hash, prev_hash and time without
+// fetching or decoding any of its transactions.
+func GetBlockHeader(blockIdentifier *types.PartialBlockIdentifier) (*BlockHeader, *types.Error) {
+	result, bErr := getRawBlock(blockIdentifier)
+	if bErr != nil {
+		return nil, bErr
 	}
 
-	ghostTxns, gtErr := utils.SeekGhostTxnsInBlock(CurrentNetwork, result.Height)
-	if gtErr != nil {
-		return nil, WrapErr(ErrFailed, gtErr)
+	return &BlockHeader{
+		Hash:     result.Hash,
+		Height:   result.Height,
+		PrevHash: result.PrevHash,
+		Time:     result.Time,
+	}, nil
+}
+
+func GetBlock(blockIdentifier *types.PartialBlockIdentifier) (*types.Block, *types.Error) {
+	if cachedBlock := getCachedBlock(blockIdentifier); cachedBlock != nil {
+		return cachedBlock, nil
 	}
 
-	if len(ghostTxns) > 0 {
-		zap.S().Info("Adding " + fmt.Sprint(len(ghostTxns)) + " ghost txns.")
-		processedTxs = append(processedTxs, ghostTxns...)
+	result, bErr := getRawBlock(blockIdentifier)
+	if bErr != nil {
+		return nil, bErr
 	}
 
-	var blockTime int64
+	blockId := &types.BlockIdentifier{
+		Index: result.Height,
+		Hash:  result.Hash,
+	}
 
-	if result.Time == 0 {
-		blockTime = 946684800
-	} else {
-		blockTime = result.Time
+	var txHashes []string
+	for _, tx := range result.Transactions {
+		txHashes = append(txHashes, fmt.Sprint(tx["hash"]))
 	}
 
-	if result.Hash == "" {
-		return nil, WrapErr(ErrNotFound, errors.New("block cannot be found"))
+	processedTxs, txErr := getTransactions(txHashes, blockId)
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	deferredTxns, dtErr := DeferredTransactions(result.Height)
+	if dtErr != nil {
+		return nil, dtErr
+	}
+
+	if len(deferredTxns) > 0 {
+		zap.S().Info("Adding " + fmt.Sprint(len(deferredTxns)) + " deferred txns.")
+		processedTxs = append(processedTxs, deferredTxns...)
+	}
+
+	// The genesis block is its own parent
+	parentBlockId := blockId
+	if result.Height > GenesisBlockIndex {
+		parentBlockId = &types.BlockIdentifier{
+			Index: result.Height - 1,
+			Hash:  result.PrevHash,
+		}
 	}
 
 	currentBlock := &types.Block{
-		BlockIdentifier: &types.BlockIdentifier{
-			Index: result.Height,
-			Hash:  result.Hash,
+		BlockIdentifier:       blockId,
+		ParentBlockIdentifier: parentBlockId,
+		Timestamp:             BlockTimestamp(result.Time),
+		Transactions:          processedTxs,
+		Metadata: map[string]interface{}{
+			"prev_hash":                   result.PrevHash,
+			"transaction_count":           len(result.Transactions),
+			"deferred_transaction_counts": deferredTxnCounts(deferredTxns),
+			"last_blessed_snapshot":       result.Height == *LBS,
 		},
-		Timestamp:    blockTime * 1000,
-		Transactions: processedTxs,
-		Metadata:     nil,
+	}
+
+	// Older nodes leave election_epoch out of block_get
+	if result.ElectionEpoch != nil {
+		currentBlock.Metadata["election_epoch"] = *result.ElectionEpoch
+	}
+
+	if cErr := cacheBlock(currentBlock); cErr != nil {
+		return nil, cErr
 	}
 
 	return currentBlock, nil
 }
 
-func GetTransaction(txHash string, block *types.BlockIdentifier) (*types.Transaction, *types.Error) {
+// GetBlockTransaction returns txHash only if the node reports it in the block
+// identified by both the height and the hash of blockIdentifier. Ghost
+// transactions of every deferred kind due at that height resolve as well.
+func GetBlockTransaction(txHash string, blockIdentifier *types.BlockIdentifier) (*types.Transaction, *types.Error) {
+	if cachedBlock := getCachedBlock(&types.PartialBlockIdentifier{Index: &blockIdentifier.Index}); cachedBlock != nil &&
+		cachedBlock.BlockIdentifier.Hash == blockIdentifier.Hash {
+		for _, txn := range cachedBlock.Transactions {
+			if txn.TransactionIdentifier.Hash == txHash {
+				return txn, nil
+			}
+		}
+	}
+
+	result, bErr := getRawBlock(&types.PartialBlockIdentifier{
+		Index: &blockIdentifier.Index,
+	})
+	if bErr != nil {
+		return nil, bErr
+	}
+
+	if result.Hash != blockIdentifier.Hash {
+		return nil, WrapErr(
+			ErrNotFound,
+			errors.New("block "+fmt.Sprint(blockIdentifier.Index)+" does not have hash "+blockIdentifier.Hash),
+		)
+	}
+
+	for _, tx := range result.Transactions {
+		if fmt.Sprint(tx["hash"]) == txHash {
+			return GetTransaction(txHash, blockIdentifier)
+		}
+	}
+
+	if deferredTxn, dtErr := DeferredTransaction(txHash, result.Height); dtErr == nil {
+		return deferredTxn, nil
+	} else if dtErr.Code != ErrNotFound.Code {
+		return nil, dtErr
+	}
+
+	return nil, WrapErr(
+		ErrNotFound,
+		errors.New("transaction "+txHash+" not found in block "+fmt.Sprint(blockIdentifier.Index)),
+	)
+}
+
+// getTransactions fetches and decodes txHashes with at most TransactionWorkers
+// requests in flight, returning them in their original order. The first
+// error stops any work that has not started yet.
+func getTransactions(txHashes []string, block *types.BlockIdentifier) ([]*types.Transaction, *types.Error) {
+	if len(txHashes) == 0 {
+		return nil, nil
+	}
+
+	workers := TransactionWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(txHashes) {
+		workers = len(txHashes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	processedTxs := make([]*types.Transaction, len(txHashes))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr *types.Error
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				ptx, txErr := GetTransaction(txHashes[i], block)
+				if txErr != nil {
+					once.Do(func() {
+						firstErr = txErr
+						cancel()
+					})
+					continue
+				}
+
+				processedTxs[i] = ptx
+			}
+		}()
+	}
+
+dispatch:
+	for i := range txHashes {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return processedTxs, nil
+}
+
+// getRawTransaction fetches a transaction as the node reports it.
+func getRawTransaction(txHash string) (map[string]interface{}, *types.Error) {
 	type request struct {
 		Hash string `json:"hash"`
 	}
@@ -174,18 +348,40 @@ func GetTransaction(txHash string, block *types.BlockIdentifier) (*types.Transac
 		)
 	}
 
+	if result == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("transaction not found: "+txHash))
+	}
+
+	return result, nil
+}
+
+func GetTransaction(txHash string, block *types.BlockIdentifier) (*types.Transaction, *types.Error) {
+	result, rErr := getRawTransaction(txHash)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	// The transaction was found, so anything missing while decoding it (an
+	// oracle price, a gateway, an HTLC) is a failure to report it
 	operations, oErr := TransactionToOps(result, SuccessStatus, block)
-	if oErr != nil {
+	if oErr != nil && oErr.Code == ErrNotFound.Code {
+		return nil, WrapErr(ErrFailed, errors.New("decoding transaction "+txHash+": "+oErr.Message+ErrorContext(oErr)))
+	} else if oErr != nil {
 		return nil, oErr
 	}
 
+	relatedTransactions, rErr := RelatedTransactions(result, block)
+	if rErr != nil {
+		return nil, rErr
+	}
+
 	transaction := &types.Transaction{
 		TransactionIdentifier: &types.TransactionIdentifier{
 			Hash: fmt.Sprint(result["hash"]),
 		},
 		Operations:          operations,
-		RelatedTransactions: nil,
-		Metadata:            nil,
+		RelatedTransactions: relatedTransactions,
+		Metadata:            TransactionMetadata(result, operations),
 	}
 
 	return transaction, nil
@@ -218,9 +414,7 @@ func GetAddress(curveType types.CurveType, publicKey []byte) (*string, *types.Er
 	return &response, nil
 }
 
-func GetBalance(balanceRequest GetBalanceRequest) ([]*types.Amount, *types.Error) {
-	var balances []*types.Amount
-
+func GetAccount(balanceRequest GetBalanceRequest) (*GetBalanceResponse, *types.Error) {
 	var result GetBalanceResponse
 
 	if err := NodeClient.CallFor(&result, "account_get", balanceRequest); err != nil {
@@ -230,22 +424,172 @@ func GetBalance(balanceRequest GetBalanceRequest) ([]*types.Amount, *types.Error
 		)
 	}
 
+	return &result, nil
+}
+
+func GetBalance(balanceRequest GetBalanceRequest) ([]*types.Amount, *types.Error) {
+	result, aErr := GetAccount(balanceRequest)
+	if aErr != nil {
+		return nil, aErr
+	}
+
+	return AccountBalances(result), nil
+}
+
+func AccountBalances(account *GetBalanceResponse) []*types.Amount {
+	var balances []*types.Amount
+
 	amountHNT := &types.Amount{
-		Value:    fmt.Sprint(result.Balance),
+		Value:    fmt.Sprint(account.Balance),
 		Currency: HNT,
 	}
 
 	amountHST := &types.Amount{
-		Value:    fmt.Sprint(result.SecBalance),
+		Value:    fmt.Sprint(account.SecBalance),
 		Currency: HST,
 	}
 
-	balances = append(balances, amountHNT, amountHST)
+	amountDC := &types.Amount{
+		Value:    fmt.Sprint(account.DCBalance),
+		Currency: DC,
+	}
 
-	return balances, nil
+	balances = append(balances, amountHNT, amountHST, amountDC)
+
+	return balances
 }
 
-func GetGatewayOwner(address string, height int64) (*string, *types.Error) {
+// SubAccountBalances returns the HNT held in an owner's staked or cooldown
+// sub-account, as summed by the node across the owner's validators.
+func SubAccountBalances(account *GetBalanceResponse, subAccount string) ([]*types.Amount, *types.Error) {
+	if subAccount != StakedSubAccount && subAccount != CooldownSubAccount {
+		return nil, WrapErr(ErrInvalidParameter, errors.New("sub-account `"+subAccount+"` not recognized"))
+	}
+
+	value := account.StakedBalance
+	if subAccount == CooldownSubAccount {
+		value = account.CooldownBalance
+	}
+
+	return []*types.Amount{
+		{
+			Value:    fmt.Sprint(value),
+			Currency: HNT,
+		},
+	}, nil
+}
+
+// GetAccountMetadata returns the nonces the node reports for an account
+// together with the kind of address it is at the given height. The kind is
+// left out if it cannot be resolved, rather than failing the balance.
+func GetAccountMetadata(account *GetBalanceResponse, height int64) (map[string]interface{}, *types.Error) {
+	metadata := map[string]interface{}{
+		"nonce":     account.Nonce,
+		"dc_nonce":  account.DCNonce,
+		"sec_nonce": account.SecNonce,
+	}
+
+	addressKind, kErr := GetAddressKind(account.Address, height)
+	if kErr != nil {
+		zap.S().Warn("Unable to resolve the address kind of " + account.Address + ": " + kErr.Message + ErrorContext(kErr))
+	} else if addressKind != "" {
+		metadata["address_kind"] = addressKind
+	}
+
+	return metadata, nil
+}
+
+// addressKindCacheSize bounds the addresses whose kind is remembered.
+const addressKindCacheSize = 100000
+
+// addressKindEntry is what is known of an address's kind. An address that
+// becomes a gateway or validator stays one, so kind holds from the lowest
+// height it was seen at, and the address was a wallet up to walletThrough.
+type addressKindEntry struct {
+	kind          string
+	since         int64
+	walletThrough int64
+}
+
+var (
+	addressKinds     = map[string]*addressKindEntry{}
+	addressKindsLock sync.Mutex
+)
+
+// GetAddressKind reports whether an address is a gateway, a validator or
+// a plain wallet at height, or "" if the node cannot tell a validator from a
+// wallet. Resolved kinds are cached, so most lookups make no node calls.
+func GetAddressKind(address string, height int64) (string, *types.Error) {
+	addressKindsLock.Lock()
+	var cached addressKindEntry
+	if entry := addressKinds[address]; entry != nil {
+		cached = *entry
+	}
+	addressKindsLock.Unlock()
+
+	if cached.kind != "" && height >= cached.since {
+		return cached.kind, nil
+	}
+	if cached.walletThrough != 0 && height <= cached.walletThrough {
+		return WalletAddress, nil
+	}
+
+	kind, kErr := resolveAddressKind(address, height)
+	if kErr != nil || kind == "" {
+		return kind, kErr
+	}
+
+	addressKindsLock.Lock()
+	entry := addressKinds[address]
+	if entry == nil {
+		if len(addressKinds) >= addressKindCacheSize {
+			addressKinds = map[string]*addressKindEntry{}
+		}
+		entry = &addressKindEntry{}
+		addressKinds[address] = entry
+	}
+	if kind == WalletAddress {
+		if height > entry.walletThrough {
+			entry.walletThrough = height
+		}
+	} else if entry.kind == "" || height < entry.since {
+		entry.kind, entry.since = kind, height
+	}
+	addressKindsLock.Unlock()
+
+	return kind, nil
+}
+
+// validatorInfoMissing is set once the node has answered that it does not
+// implement ValidatorInfoMethod, so that it is not asked again.
+var validatorInfoMissing int32
+
+func resolveAddressKind(address string, height int64) (string, *types.Error) {
+	_, gErr := GetGatewayInfo(address, height)
+	if gErr == nil {
+		return GatewayAddress, nil
+	} else if gErr.Code != ErrNotFound.Code {
+		return "", gErr
+	}
+
+	if atomic.LoadInt32(&validatorInfoMissing) != 0 {
+		return "", nil
+	}
+
+	_, vErr := GetValidator(address, height)
+	if vErr == nil {
+		return ValidatorAddress, nil
+	} else if vErr.Code == ErrUnimplemented.Code {
+		atomic.StoreInt32(&validatorInfoMissing, 1)
+		return "", nil
+	} else if vErr.Code != ErrNotFound.Code {
+		return "", vErr
+	}
+
+	return WalletAddress, nil
+}
+
+func GetGatewayInfo(address string, height int64) (map[string]interface{}, *types.Error) {
 	type request struct {
 		Address string `json:"address"`
 		Height  int64  `json:"height"`
@@ -261,11 +605,55 @@ func GetGatewayOwner(address string, height int64) (*string, *types.Error) {
 		)
 	}
 
+	if result == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("gateway not found: "+address))
+	}
+
+	return result, nil
+}
+
+func GetGatewayOwner(address string, height int64) (*string, *types.Error) {
+	result, gErr := GetGatewayInfo(address, height)
+	if gErr != nil {
+		return nil, gErr
+	}
+
 	owner := fmt.Sprint(result["owner_address"])
 
 	return &owner, nil
 }
 
+// ValidatorInfoMethod is the node call GetValidator makes. It mirrors
+// gateway_info_get; a node without it fails with ErrUnimplemented rather
+// than reporting every validator as not found.
+const ValidatorInfoMethod = "validator_info_get"
+
+func GetValidator(address string, height int64) (map[string]interface{}, *types.Error) {
+	type request struct {
+		Address string `json:"address"`
+		Height  int64  `json:"height"`
+	}
+
+	req := request{Address: address, Height: height}
+
+	result, err := utils.DecodeCallAsNumber(NodeClient.Call(ValidatorInfoMethod, req))
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == utils.NodeMethodNotFoundCode {
+		return nil, WrapErr(ErrUnimplemented, errors.New("node does not implement "+ValidatorInfoMethod))
+	} else if err != nil {
+		return nil, WrapErr(
+			ErrFailed,
+			err,
+		)
+	}
+
+	if result == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("validator not found: "+address))
+	}
+
+	return result, nil
+}
+
 func GetHash(signedTransaction string) (*string, *types.Error) {
 	jsonObject, jErr := json.Marshal(hashRequest{
 		Transaction: signedTransaction,
@@ -304,6 +692,10 @@ func GetHTLCReceipt(address string) (*HTLCReceipt, *types.Error) {
 		)
 	}
 
+	if result == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("htlc not found: "+address))
+	}
+
 	htlcReceipt := &HTLCReceipt{
 		Address:    address,
 		Balance:    utils.JsonNumberToInt64(result["balance"]),
@@ -317,6 +709,72 @@ func GetHTLCReceipt(address string) (*HTLCReceipt, *types.Error) {
 	return htlcReceipt, nil
 }
 
+// GetHTLCBalance returns the HNT escrowed at an HTLC address as of the
+// given height, or ErrNotFound if address is not an HTLC. The escrow is empty
+// before the HTLC is created and once it is redeemed or refunded.
+//
+// account is the address's ledger account at height, or nil if it has none.
+// The node is only asked for the HTLC if address is in the HTLC index or its
+// account is empty, as an HTLC address's is. An HTLC whose create_htlc_v1 has
+// not been indexed fails, since its escrow before the create is unknown.
+func GetHTLCBalance(address string, account *GetBalanceResponse, height int64) ([]*types.Amount, *types.Error) {
+	createdAt, indexed, cErr := htlcCreateHeight(address)
+	if cErr != nil {
+		return nil, cErr
+	}
+
+	if !indexed && account != nil && !isEmptyAccount(account) {
+		return nil, WrapErr(ErrNotFound, errors.New("not an htlc: "+address))
+	}
+
+	htlcReceipt, hErr := GetHTLCReceipt(address)
+	if hErr != nil {
+		return nil, hErr
+	}
+
+	if !indexed {
+		return nil, WrapErr(ErrFailed, errors.New("the create_htlc_v1 of htlc "+address+" has not been indexed"))
+	}
+
+	return []*types.Amount{
+		{
+			Value:    fmt.Sprint(htlcBalanceAt(htlcReceipt, createdAt, height)),
+			Currency: HNT,
+		},
+	}, nil
+}
+
+func isEmptyAccount(account *GetBalanceResponse) bool {
+	return account.Balance == 0 && account.SecBalance == 0 && account.DCBalance == 0 &&
+		account.StakedBalance == 0 && account.CooldownBalance == 0 &&
+		account.Nonce == 0 && account.SecNonce == 0 && account.DCNonce == 0
+}
+
+// htlcCreateHeight returns the height of the create_htlc_v1 that funded an
+// HTLC address from the HTLC index, and whether the index has it.
+func htlcCreateHeight(address string) (int64, bool, *types.Error) {
+	ref, gErr := utils.GetHTLCTxn(CurrentNetwork, address, utils.HTLCCreateStage)
+	if gErr == badger.ErrKeyNotFound {
+		return 0, false, nil
+	} else if gErr != nil {
+		return 0, false, WrapErr(ErrFailed, gErr)
+	}
+
+	return ref.Block.Index, true, nil
+}
+
+// htlcBalanceAt is an HTLC's escrow at height, given the height it was
+// created at.
+func htlcBalanceAt(htlcReceipt *HTLCReceipt, createdAt int64, height int64) int64 {
+	if height < createdAt {
+		return 0
+	}
+	if htlcReceipt.RedeemedAt != 0 && height >= htlcReceipt.RedeemedAt {
+		return 0
+	}
+	return htlcReceipt.Balance
+}
+
 func GetNonce(address string) (*int64, *types.Error) {
 	var nonce int64
 
@@ -334,6 +792,10 @@ func GetNonce(address string) (*int64, *types.Error) {
 		)
 	}
 
+	if result == nil || result["nonce"] == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("account not found: "+address))
+	}
+
 	nonce = utils.JsonNumberToInt64(result["nonce"])
 
 	return &nonce, nil
@@ -354,6 +816,10 @@ func GetOraclePrice(height int64) (*int64, *types.Error) {
 		)
 	}
 
+	if result == nil || result["price"] == nil {
+		return nil, WrapErr(ErrNotFound, errors.New("no oracle price at height "+fmt.Sprint(height)))
+	}
+
 	price := utils.JsonNumberToInt64(result["price"])
 
 	return &price, nil
@@ -401,22 +867,32 @@ func GetFee(hash *string, DCFeeAmount int64) (*Fee, *types.Error) {
 	return feeResult, nil
 }
 
+// GetChainVars fetches the current chain variables from the
+// transaction constructor service.
+func GetChainVars() (map[string]interface{}, error) {
+	var chainVars map[string]interface{}
+	resp, vErr := http.Get("http://localhost:3000/chain-vars")
+	if vErr != nil {
+		return nil, vErr
+	}
+	defer resp.Body.Close()
+	if dErr := json.NewDecoder(resp.Body).Decode(&chainVars); dErr != nil {
+		return nil, dErr
+	}
+
+	return chainVars, nil
+}
+
 func GetMetadata(request *types.ConstructionMetadataRequest) (*types.ConstructionMetadataResponse, *types.Error) {
 	metadataResponse := types.ConstructionMetadataResponse{
 		Metadata: map[string]interface{}{},
 	}
 
 	// Get chain_vars (default metadata)
-	var chainVars map[string]interface{}
-	resp, vErr := http.Get("http://localhost:3000/chain-vars")
+	chainVars, vErr := GetChainVars()
 	if vErr != nil {
 		return nil, WrapErr(ErrUnclearIntent, vErr)
 	}
-	defer resp.Body.Close()
-	dErr := json.NewDecoder(resp.Body).Decode(&chainVars)
-	if dErr != nil {
-		return nil, WrapErr(ErrUnclearIntent, dErr)
-	}
 	metadataResponse.Metadata["chain_vars"] = chainVars
 
 	// Get raw options
@@ -0,0 +1,49 @@
+package helium
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ybbus/jsonrpc"
+)
+
+func TestBlockMetadataElectionEpoch(t *testing.T) {
+	openTestDB(t)
+
+	startTestNode(t, map[string]nodeHandler{
+		"block_height": func(map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			return 100, nil
+		},
+		"block_get": func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			height := int64(params["height"].(float64))
+			block := map[string]interface{}{
+				"hash":         "hash" + fmt.Sprint(height),
+				"height":       height,
+				"prev_hash":    "prev",
+				"time":         1600000000,
+				"transactions": []interface{}{},
+			}
+			if height == 50 {
+				block["election_epoch"] = 7
+			}
+			return block, nil
+		},
+	})
+
+	for _, height := range []int64{50, 51} {
+		blockHeight := height
+		block, bErr := GetBlock(&types.PartialBlockIdentifier{Index: &blockHeight})
+		if bErr != nil {
+			t.Fatalf("height %d: %v", height, bErr)
+		}
+
+		epoch, ok := block.Metadata["election_epoch"]
+		if height == 50 && (!ok || fmt.Sprint(epoch) != "7") {
+			t.Errorf("height 50: election_epoch = %v, want 7", epoch)
+		}
+		if height == 51 && ok {
+			t.Errorf("height 51: election_epoch = %v, want it left out", epoch)
+		}
+	}
+}
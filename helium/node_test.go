@@ -0,0 +1,83 @@
+package helium
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/helium/rosetta-helium/utils"
+	"github.com/ybbus/jsonrpc"
+)
+
+// nodeHandler answers one JSON-RPC method of the test node.
+type nodeHandler func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError)
+
+// testNode is a fake blockchain-node that NodeClient talks to during a test.
+// Methods without a handler fail as unknown methods.
+type testNode struct {
+	handlers map[string]nodeHandler
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func startTestNode(t *testing.T, handlers map[string]nodeHandler) *testNode {
+	t.Helper()
+
+	node := &testNode{handlers: handlers, calls: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(node.serve))
+
+	previous := NodeClient
+	NodeClient = jsonrpc.NewClient(server.URL)
+
+	t.Cleanup(func() {
+		server.Close()
+		NodeClient = previous
+	})
+
+	return node
+}
+
+func (n *testNode) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     int             `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Methods without parameters, such as block_height, are sent with an
+	// array for params
+	var params map[string]interface{}
+	json.Unmarshal(request.Params, &params)
+
+	n.mu.Lock()
+	n.calls[request.Method]++
+	n.mu.Unlock()
+
+	response := &jsonrpc.RPCResponse{JSONRPC: "2.0", ID: request.ID}
+	if handler, ok := n.handlers[request.Method]; ok {
+		response.Result, response.Error = handler(params)
+	} else {
+		response.Error = &jsonrpc.RPCError{Code: utils.NodeMethodNotFoundCode, Message: "Method not found"}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// callCount is how often method has been called.
+func (n *testNode) callCount(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+// nodeNotFound is the error the node answers for a missing object.
+func nodeNotFound(map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+	return nil, &jsonrpc.RPCError{Code: utils.NodeNotFoundCode, Message: "not_found"}
+}
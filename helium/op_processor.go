@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/helium/rosetta-helium/utils"
@@ -180,13 +181,28 @@ func TransactionToOps(txn map[string]interface{}, status string, block *types.Bl
 		if feeErr != nil {
 			return nil, feeErr
 		}
+		if block == nil {
+			return nil, WrapErr(ErrInvalidParameter, errors.New("token_burn_v1 requires a block to price the burn"))
+		}
+		oraclePrice, opErr := GetOraclePrice(block.Index)
+		if opErr != nil {
+			return nil, opErr
+		}
 		return TokenBurnV1(
 			fmt.Sprint(txn["payer"]),
+			fmt.Sprint(txn["payee"]),
 			utils.JsonNumberToInt64(txn["amount"]),
+			HNTToDC(utils.JsonNumberToInt64(txn["amount"]), *oraclePrice),
 			feeDetails,
 			txn,
 		)
 
+	case DCCoinbaseV1Txn:
+		return DCCoinbaseV1(
+			fmt.Sprint(txn["payee"]),
+			utils.JsonNumberToInt64(txn["amount"]),
+		)
+
 	case TransferHotspotV1Txn:
 		feeDetails, feeErr := GetFee(&hash, utils.JsonNumberToInt64(txn["fee"]))
 		if feeErr != nil {
@@ -230,23 +246,39 @@ func TransactionToOps(txn map[string]interface{}, status string, block *types.Bl
 		if feeErr != nil {
 			return nil, feeErr
 		}
+		unstakeBlock := int64(0)
+		if block != nil {
+			unstakeBlock = block.Index
+		}
 		return UnstakeValidatorV1(
 			fmt.Sprint(txn["owner"]),
 			utils.JsonNumberToInt64(txn["stake_amount"]),
 			utils.JsonNumberToInt64(txn["stake_release_height"]),
+			unstakeBlock,
 			feeDetails,
 			txn,
 		)
 
+	case GenValidatorV1Txn:
+		return GenValidatorV1(
+			fmt.Sprint(txn["owner"]),
+			utils.JsonNumberToInt64(txn["stake"]),
+			txn,
+		)
+
 	case TransferValidatorStakeV1Txn:
 		feeDetails, feeErr := GetFee(&hash, utils.JsonNumberToInt64(txn["fee"]))
 		if feeErr != nil {
 			return nil, feeErr
 		}
+		if sErr := scheduleStakeTransferCooldown(txn, block); sErr != nil {
+			return nil, sErr
+		}
 		return TransferValidatorStakeV1(
 			fmt.Sprint(txn["new_owner"]),
 			fmt.Sprint(txn["old_owner"]),
 			utils.JsonNumberToInt64(txn["payment_amount"]),
+			utils.JsonNumberToInt64(txn["stake_amount"]),
 			feeDetails,
 			txn,
 		)
@@ -301,21 +333,45 @@ func TransactionToOps(txn map[string]interface{}, status string, block *types.Bl
 		if feeErr != nil {
 			return nil, feeErr
 		}
-		return FeeOnlyTxn(
-			StateChannelOpenOp,
-			"",
+		if sErr := scheduleStateChannelExpiry(txn, block); sErr != nil {
+			return nil, sErr
+		}
+		return StateChannelOpenV1(
 			fmt.Sprint(txn["owner"]),
+			utils.JsonNumberToInt64(txn["amount"]),
 			feeDetails,
 			txn,
 		)
 
+	case StateChannelCloseV1Txn:
+		stateChannel, ok := txn["state_channel"].(map[string]interface{})
+		if !ok {
+			return nil, WrapErr(ErrUnableToParseTxn, errors.New("state_channel_close_v1 missing state_channel"))
+		}
+		refund, rErr := StateChannelRefund(stateChannel)
+		if rErr != nil {
+			return nil, rErr
+		}
+		if cErr := closeStateChannel(fmt.Sprint(stateChannel["owner"]), fmt.Sprint(stateChannel["id"]), block); cErr != nil {
+			return nil, cErr
+		}
+		return StateChannelCloseV1(
+			fmt.Sprint(stateChannel["owner"]),
+			refund,
+			txn,
+		)
+
 	case CreateHTLCV1Txn:
 		feeDetails, feeErr := GetFee(&hash, utils.JsonNumberToInt64(txn["fee"]))
 		if feeErr != nil {
 			return nil, feeErr
 		}
+		if sErr := scheduleHTLCTimeout(txn, block); sErr != nil {
+			return nil, sErr
+		}
 		return CreateHTLCV1(
 			fmt.Sprint(txn["payer"]),
+			fmt.Sprint(txn["address"]),
 			utils.JsonNumberToInt64(txn["amount"]),
 			feeDetails,
 			map[string]interface{}{
@@ -339,6 +395,8 @@ func TransactionToOps(txn map[string]interface{}, status string, block *types.Bl
 
 		return RedeemHTLCV1(
 			fmt.Sprint(txn["payee"]),
+			htlcDetails.Payer,
+			address,
 			htlcDetails.Balance,
 			feeDetails,
 			map[string]interface{}{
@@ -360,3 +418,33 @@ func TransactionToOps(txn map[string]interface{}, status string, block *types.Bl
 		return nil, WrapErr(ErrNotFound, errors.New("txn type not found: "+fmt.Sprint(txn["type"])))
 	}
 }
+
+// TransactionMetadata summarises a decoded transaction: its Helium type,
+// its nonce when it has one, and the fee recorded by its fee_op.
+func TransactionMetadata(txn map[string]interface{}, operations []*types.Operation) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"type": fmt.Sprint(txn["type"]),
+	}
+
+	if txn["nonce"] != nil {
+		metadata["nonce"] = utils.JsonNumberToInt64(txn["nonce"])
+	}
+
+	for _, op := range operations {
+		if op.Type != FeeOp {
+			continue
+		}
+
+		metadata["dc_fee"] = op.Metadata["dc_fee"]
+		metadata["implicit_burn"] = op.Metadata["implicit_burn"]
+		if op.Amount != nil {
+			metadata["fee"] = map[string]interface{}{
+				"value":    strings.TrimPrefix(op.Amount.Value, "-"),
+				"currency": op.Amount.Currency,
+			}
+		}
+		break
+	}
+
+	return metadata
+}
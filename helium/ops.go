@@ -3,8 +3,10 @@ package helium
 import (
 	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
 )
 
 func CreateGenericOp(opType string, status string, opIndex int64, metadata map[string]interface{}) (*types.Operation, *types.Error) {
@@ -88,6 +90,50 @@ func CreateCreditOp(
 	return creditOp, nil
 }
 
+func CreateSubAccountDebitOp(
+	opType,
+	payer,
+	subAccount string,
+	amount int64,
+	currency *types.Currency,
+	status string,
+	opIndex int64,
+	metadata map[string]interface{},
+) (*types.Operation, *types.Error) {
+	debitOp, dErr := CreateDebitOp(opType, payer, amount, currency, status, opIndex, metadata)
+	if dErr != nil {
+		return nil, dErr
+	}
+
+	debitOp.Account.SubAccount = &types.SubAccountIdentifier{
+		Address: subAccount,
+	}
+
+	return debitOp, nil
+}
+
+func CreateSubAccountCreditOp(
+	opType,
+	payee,
+	subAccount string,
+	amount int64,
+	currency *types.Currency,
+	status string,
+	opIndex int64,
+	metadata map[string]interface{},
+) (*types.Operation, *types.Error) {
+	creditOp, cErr := CreateCreditOp(opType, payee, amount, currency, status, opIndex, metadata)
+	if cErr != nil {
+		return nil, cErr
+	}
+
+	creditOp.Account.SubAccount = &types.SubAccountIdentifier{
+		Address: subAccount,
+	}
+
+	return creditOp, nil
+}
+
 func CreateFeeOp(payer string, fee *Fee, status string, opIndex int64, metadata map[string]interface{}) (*types.Operation, *types.Error) {
 	FeeOpObject := &types.Operation{
 		OperationIdentifier: &types.OperationIdentifier{
@@ -115,6 +161,10 @@ func CreateFeeOp(payer string, fee *Fee, status string, opIndex int64, metadata
 		metadata["dc_fee"] = fee.DCFeeAmount
 		FeeOpObject.Metadata = metadata
 	case "DC":
+		FeeOpObject.Amount = &types.Amount{
+			Value:    "-" + fmt.Sprint(fee.Amount),
+			Currency: DC,
+		}
 		metadata["debit_category"] = "fee"
 		metadata["implicit_burn"] = false
 		metadata["dc_fee"] = fee.DCFeeAmount
@@ -129,3 +179,36 @@ func CreateFeeOp(payer string, fee *Fee, status string, opIndex int64, metadata
 
 	return FeeOpObject, nil
 }
+
+// HNTToDC converts an amount of HNT bones into DC at the given oracle price,
+// truncating like the ledger does. The oracle price is in 1e-8 USD and one DC
+// is worth 1e-5 USD.
+func HNTToDC(amount int64, oraclePrice int64) int64 {
+	dc := new(big.Int).Mul(big.NewInt(amount), big.NewInt(oraclePrice))
+	dc.Quo(dc, big.NewInt(100000000000))
+	return dc.Int64()
+}
+
+// StateChannelRefund returns the DC committed to a closed state channel that
+// was not spent on the packets in its summaries.
+func StateChannelRefund(stateChannel map[string]interface{}) (int64, *types.Error) {
+	if stateChannel["amount"] == nil {
+		return 0, WrapErr(ErrUnableToParseTxn, errors.New("state channel missing amount"))
+	}
+	refund := utils.JsonNumberToInt64(stateChannel["amount"])
+
+	summaries, _ := stateChannel["summaries"].([]interface{})
+	for _, summary := range summaries {
+		numDCs, ok := summary.(map[string]interface{})["num_dcs"]
+		if !ok {
+			continue
+		}
+		refund -= utils.JsonNumberToInt64(numDCs)
+	}
+
+	if refund < 0 {
+		refund = 0
+	}
+
+	return refund, nil
+}
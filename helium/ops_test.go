@@ -0,0 +1,73 @@
+package helium
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ybbus/jsonrpc"
+)
+
+func TestHNTToDC(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		oraclePrice int64
+		want        int64
+	}{
+		// DC are worth $0.00001; amounts are in bones and prices in 1e-8 USD
+		{"1 HNT at $10", 100000000, 1000000000, 1000000},
+		{"1 HNT at $1.23456789", 100000000, 123456789, 123456},
+		{"1 bone rounds down", 1, 1000000000, 0},
+		{"no amount", 0, 1000000000, 0},
+		// amount * price overflows int64
+		{"100M HNT at $100", 10000000000000000, 10000000000, 1000000000000000},
+	}
+
+	for _, test := range tests {
+		if got := HNTToDC(test.amount, test.oraclePrice); got != test.want {
+			t.Errorf("%s: HNTToDC(%d, %d) = %d, want %d", test.name, test.amount, test.oraclePrice, got, test.want)
+		}
+	}
+}
+
+func TestTokenBurnV1Pricing(t *testing.T) {
+	startTestNode(t, map[string]nodeHandler{
+		"implicit_burn_get": nodeNotFound,
+		"transaction_get": func(map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			return map[string]interface{}{
+				"type":   TokenBurnV1Txn,
+				"hash":   "burn",
+				"payer":  "payer",
+				"payee":  "payee",
+				"amount": 100000000,
+				"fee":    0,
+			}, nil
+		},
+		"oracle_price_get": func(params map[string]interface{}) (interface{}, *jsonrpc.RPCError) {
+			switch fmt.Sprint(params["height"]) {
+			case "1000":
+				return map[string]interface{}{"price": 1000000000}, nil
+			case "2000":
+				return nil, &jsonrpc.RPCError{Code: -32603, Message: "internal error"}
+			}
+			return nodeNotFound(params)
+		},
+	})
+
+	txn, tErr := GetTransaction("burn", &types.BlockIdentifier{Index: 1000})
+	if tErr != nil {
+		t.Fatal(tErr)
+	}
+	if credit := txn.Operations[1].Amount; credit.Currency != DC || credit.Value != "1000000" {
+		t.Fatalf("priced burn: got DC credit %v", credit)
+	}
+
+	// A burn that cannot be priced fails its block instead of reporting the
+	// block as not found
+	for _, height := range []int64{500, 2000} {
+		if _, tErr := GetTransaction("burn", &types.BlockIdentifier{Index: height}); tErr == nil || tErr.Code != ErrFailed.Code {
+			t.Fatalf("at %d: got %v, want ErrFailed", height, tErr)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package helium
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// RelatedTransactions links transactions whose effects span more than one
+// block: an unstake to the ghost transaction releasing its stake, and an
+// HTLC's create to the redeem that spends it (and back). Deferred
+// transactions of the other kinds link back to their source themselves.
+func RelatedTransactions(txn map[string]interface{}, block *types.BlockIdentifier) ([]*types.RelatedTransaction, *types.Error) {
+	hash := fmt.Sprint(txn["hash"])
+
+	switch txn["type"] {
+	case UnstakeValidatorV1Txn:
+		ghostHash := utils.GhostTxnHash(&utils.GhostTxnKey{
+			Network: CurrentNetwork,
+			Block: &types.BlockIdentifier{
+				Index: utils.JsonNumberToInt64(txn["stake_release_height"]),
+			},
+			Transaction: &types.TransactionIdentifier{
+				Hash: hash,
+			},
+		})
+
+		return []*types.RelatedTransaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: ghostHash,
+				},
+				Direction: types.Forward,
+			},
+		}, nil
+
+	case CreateHTLCV1Txn:
+		return htlcRelatedTransactions(fmt.Sprint(txn["address"]), hash, block, utils.HTLCCreateStage, utils.HTLCRedeemStage, types.Forward)
+
+	case RedeemHTLCV1Txn:
+		related, rErr := htlcRelatedTransactions(fmt.Sprint(txn["address"]), hash, block, utils.HTLCRedeemStage, utils.HTLCCreateStage, types.Backward)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		// Once the redeem is indexed, the HTLC's timeout can be dropped
+		if block != nil {
+			if cErr := cancelHTLCTimeout(fmt.Sprint(txn["address"]), block.Index); cErr != nil {
+				return nil, cErr
+			}
+		}
+
+		return related, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// htlcRelatedTransactions records hash as the given stage of the HTLC at
+// address and links it to the other stage if that has been processed. A
+// newly recorded stage invalidates the stored block of the other one so that
+// it is rebuilt with the reverse link.
+func htlcRelatedTransactions(
+	address,
+	hash string,
+	block *types.BlockIdentifier,
+	stage,
+	otherStage string,
+	direction types.Direction,
+) ([]*types.RelatedTransaction, *types.Error) {
+	if block != nil {
+		existing, eErr := utils.GetHTLCTxn(CurrentNetwork, address, stage)
+		if eErr != nil && eErr != badger.ErrKeyNotFound {
+			return nil, WrapErr(ErrFailed, eErr)
+		}
+
+		if eErr == badger.ErrKeyNotFound || existing.Transaction.Hash != hash {
+			if pErr := utils.PutHTLCTxn(CurrentNetwork, address, stage, &utils.HTLCTxnRef{
+				Block:       block,
+				Transaction: &types.TransactionIdentifier{Hash: hash},
+			}); pErr != nil {
+				return nil, WrapErr(ErrFailed, pErr)
+			}
+
+			other, oErr := utils.GetHTLCTxn(CurrentNetwork, address, otherStage)
+			if oErr == nil {
+				if iErr := InvalidateBlock(other.Block.Index); iErr != nil {
+					return nil, WrapErr(ErrFailed, iErr)
+				}
+			} else if oErr != badger.ErrKeyNotFound {
+				return nil, WrapErr(ErrFailed, oErr)
+			}
+		}
+	}
+
+	other, oErr := utils.GetHTLCTxn(CurrentNetwork, address, otherStage)
+	if oErr == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if oErr != nil {
+		return nil, WrapErr(ErrFailed, oErr)
+	}
+
+	if other.Transaction == nil {
+		return nil, WrapErr(ErrFailed, errors.New("htlc index entry for "+address+" has no transaction"))
+	}
+
+	return []*types.RelatedTransaction{
+		{
+			TransactionIdentifier: other.Transaction,
+			Direction:             direction,
+		},
+	}, nil
+}
@@ -0,0 +1,298 @@
+package helium
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+const (
+	// DefaultSearchLimit is the number of transactions returned
+	// by /search/transactions when no limit is requested
+	DefaultSearchLimit = int64(25)
+
+	// MaxSearchLimit caps the number of transactions returned
+	// by /search/transactions
+	MaxSearchLimit = int64(250)
+)
+
+// IndexBlock adds every transaction in block to the search index.
+func IndexBlock(block *types.Block) error {
+	for _, txn := range block.Transactions {
+		values := map[string][]string{
+			utils.SearchByTransaction:     {txn.TransactionIdentifier.Hash},
+			utils.SearchByTransactionType: {transactionType(txn)},
+		}
+		// A rewards transaction can credit tens of thousands of accounts, so
+		// values already added are tracked in a set rather than searched for
+		seen := map[string]struct{}{}
+		add := func(dimension, value string) {
+			if _, ok := seen[dimension+"\x00"+value]; ok {
+				return
+			}
+			seen[dimension+"\x00"+value] = struct{}{}
+			values[dimension] = append(values[dimension], value)
+		}
+
+		for _, op := range txn.Operations {
+			add(utils.SearchByOperationType, op.Type)
+			if op.Account != nil {
+				add(utils.SearchByAccount, op.Account.Address)
+				if op.Account.SubAccount != nil {
+					add(utils.SearchBySubAccount, subAccountValue(op.Account.Address, op.Account.SubAccount.Address))
+				}
+			}
+			if op.Amount != nil && op.Amount.Currency != nil {
+				add(utils.SearchByCurrency, currencyValue(op.Amount.Currency))
+			}
+			if op.Status != nil {
+				add(utils.SearchByStatus, *op.Status)
+				add(utils.SearchBySuccess, strconv.FormatBool(*op.Status == SuccessStatus))
+			}
+		}
+
+		if err := utils.PutSearchEntries(CurrentNetwork, ParserVersion, &utils.SearchEntry{
+			Block:       block.BlockIdentifier,
+			Transaction: txn.TransactionIdentifier,
+		}, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func subAccountValue(address, subAccount string) string {
+	return address + "/" + subAccount
+}
+
+func currencyValue(currency *types.Currency) string {
+	return currency.Symbol + "/" + fmt.Sprint(currency.Decimals)
+}
+
+// transactionType is the Helium transaction type recorded in a transaction's
+// metadata, or the type of the deferred kind a ghost transaction belongs to.
+func transactionType(txn *types.Transaction) string {
+	if kind := deferredKindOfHash(txn.TransactionIdentifier.Hash); kind != nil {
+		return kind.TxnType
+	}
+	return fmt.Sprint(txn.Metadata["type"])
+}
+
+// SearchTransactions implements /search/transactions over the search index.
+// Every condition is answered from the index, so only the transactions
+// returned are read. A type condition matches either an operation type or a
+// Helium transaction type.
+func SearchTransactions(request *types.SearchTransactionsRequest) (*types.SearchTransactionsResponse, *types.Error) {
+	if request.CoinIdentifier != nil {
+		return nil, WrapErr(ErrInvalidParameter, errors.New("coin_identifier is not supported: Helium is account based"))
+	}
+
+	operator := types.AND
+	if request.Operator != nil {
+		operator = *request.Operator
+	}
+	if operator != types.AND && operator != types.OR {
+		return nil, WrapErr(ErrInvalidParameter, errors.New("operator must be `and` or `or`"))
+	}
+
+	offset := int64(0)
+	if request.Offset != nil {
+		if *request.Offset < 0 {
+			return nil, WrapErr(ErrInvalidParameter, errors.New("offset cannot be negative"))
+		}
+		offset = *request.Offset
+	}
+
+	limit := DefaultSearchLimit
+	if request.Limit != nil {
+		if *request.Limit <= 0 {
+			return nil, WrapErr(ErrInvalidParameter, errors.New("limit must be positive"))
+		}
+		limit = *request.Limit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	indexed, iErr := indexedConditions(request)
+	if iErr != nil {
+		return nil, iErr
+	}
+	if len(indexed) == 0 {
+		return nil, WrapErr(ErrInvalidParameter, errors.New("at least one search condition is required"))
+	}
+
+	candidates := indexed[0]
+	for _, entries := range indexed[1:] {
+		if operator == types.AND {
+			candidates = intersectEntries(candidates, entries)
+		} else {
+			candidates = unionEntries(candidates, entries)
+		}
+	}
+
+	if request.MaxBlock != nil {
+		var bounded []*utils.SearchEntry
+		for _, entry := range candidates {
+			if entry.Block.Index <= *request.MaxBlock {
+				bounded = append(bounded, entry)
+			}
+		}
+		candidates = bounded
+	}
+
+	// Most recent blocks first, as Rosetta requires
+	sort.Slice(candidates, func(a, b int) bool {
+		if candidates[a].Block.Index != candidates[b].Block.Index {
+			return candidates[a].Block.Index > candidates[b].Block.Index
+		}
+		return candidates[a].Transaction.Hash < candidates[b].Transaction.Hash
+	})
+
+	// Entries whose transaction is gone (a dropped or quarantined deferred
+	// effect, or a block the node no longer reports) are skipped and not
+	// counted once they are read
+	totalCount := int64(len(candidates))
+	var matches []*types.BlockTransaction
+
+	next := offset
+	for ; next < int64(len(candidates)) && int64(len(matches)) < limit; next++ {
+		entry := candidates[next]
+
+		txn, tErr := GetBlockTransaction(entry.Transaction.Hash, entry.Block)
+		if tErr != nil && tErr.Code == ErrNotFound.Code {
+			totalCount--
+			continue
+		} else if tErr != nil {
+			return nil, tErr
+		}
+
+		matches = append(matches, &types.BlockTransaction{
+			BlockIdentifier: entry.Block,
+			Transaction:     txn,
+		})
+	}
+
+	response := &types.SearchTransactionsResponse{
+		Transactions: matches,
+		TotalCount:   totalCount,
+	}
+
+	if next < int64(len(candidates)) {
+		response.NextOffset = &next
+	}
+
+	return response, nil
+}
+
+// indexedConditions returns the index entries matching each condition of
+// request that can be answered from the search index.
+func indexedConditions(request *types.SearchTransactionsRequest) ([][]*utils.SearchEntry, *types.Error) {
+	var indexed [][]*utils.SearchEntry
+
+	scan := func(dimension, value string) *types.Error {
+		if len(value) > utils.MaxKeyStringLength {
+			return WrapErr(ErrInvalidParameter, errors.New(dimension+" is longer than "+fmt.Sprint(utils.MaxKeyStringLength)+" bytes"))
+		}
+		entries, err := utils.ScanSearchEntries(CurrentNetwork, ParserVersion, dimension, value)
+		if err != nil {
+			return WrapErr(ErrFailed, err)
+		}
+		indexed = append(indexed, entries)
+		return nil
+	}
+
+	if request.TransactionIdentifier != nil {
+		if err := scan(utils.SearchByTransaction, request.TransactionIdentifier.Hash); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.AccountIdentifier != nil {
+		dimension, value := utils.SearchByAccount, request.AccountIdentifier.Address
+		if request.AccountIdentifier.SubAccount != nil {
+			dimension = utils.SearchBySubAccount
+			value = subAccountValue(request.AccountIdentifier.Address, request.AccountIdentifier.SubAccount.Address)
+		}
+		if err := scan(dimension, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Address != nil {
+		if err := scan(utils.SearchByAccount, *request.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Type != nil {
+		dimension := utils.SearchByOperationType
+		if isDeferredTxnType(*request.Type) || utils.StringInSlice(*request.Type, TransactionTypes) {
+			dimension = utils.SearchByTransactionType
+		}
+		if err := scan(dimension, *request.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Currency != nil {
+		if err := scan(utils.SearchByCurrency, currencyValue(request.Currency)); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Status != nil {
+		if err := scan(utils.SearchByStatus, *request.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Success != nil {
+		if err := scan(utils.SearchBySuccess, strconv.FormatBool(*request.Success)); err != nil {
+			return nil, err
+		}
+	}
+
+	return indexed, nil
+}
+
+func searchEntryKey(entry *utils.SearchEntry) string {
+	return fmt.Sprint(entry.Block.Index) + "/" + entry.Transaction.Hash
+}
+
+func intersectEntries(a, b []*utils.SearchEntry) []*utils.SearchEntry {
+	inB := map[string]bool{}
+	for _, entry := range b {
+		inB[searchEntryKey(entry)] = true
+	}
+
+	var intersection []*utils.SearchEntry
+	for _, entry := range a {
+		if inB[searchEntryKey(entry)] {
+			intersection = append(intersection, entry)
+		}
+	}
+
+	return intersection
+}
+
+func unionEntries(a, b []*utils.SearchEntry) []*utils.SearchEntry {
+	seen := map[string]bool{}
+	var union []*utils.SearchEntry
+
+	for _, entries := range [][]*utils.SearchEntry{a, b} {
+		for _, entry := range entries {
+			if key := searchEntryKey(entry); !seen[key] {
+				seen[key] = true
+				union = append(union, entry)
+			}
+		}
+	}
+
+	return union
+}
@@ -0,0 +1,60 @@
+package helium
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// TestIndexBlockLargeRewards indexes a rewards transaction crediting more
+// accounts than fit in a single badger transaction.
+func TestIndexBlockLargeRewards(t *testing.T) {
+	openTestDB(t)
+
+	const accounts = 50000
+
+	status := SuccessStatus
+	operations := make([]*types.Operation, accounts)
+	for i := range operations {
+		operations[i] = &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+			Type:                CreditOp,
+			Status:              &status,
+			Account:             &types.AccountIdentifier{Address: "account" + fmt.Sprint(i)},
+			Amount:              &types.Amount{Value: "1", Currency: HNT},
+		}
+	}
+
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{Index: 10, Hash: "block10"},
+		Transactions: []*types.Transaction{{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: "rewards"},
+			Operations:            operations,
+			Metadata:              map[string]interface{}{"type": RewardsV2Txn},
+		}},
+	}
+
+	if err := IndexBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []string{"account0", "account" + fmt.Sprint(accounts-1)} {
+		entries, err := utils.ScanSearchEntries(CurrentNetwork, ParserVersion, utils.SearchByAccount, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Transaction.Hash != "rewards" {
+			t.Fatalf("%s: got %v, want the rewards transaction", address, entries)
+		}
+	}
+
+	entries, err := utils.ScanSearchEntries(CurrentNetwork, ParserVersion, utils.SearchByOperationType, CreditOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("credit_op: got %d entries, want 1", len(entries))
+	}
+}
@@ -1,10 +1,10 @@
 package helium
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
-	"github.com/dgraph-io/badger/v3"
 	"github.com/helium/rosetta-helium/utils"
 )
 
@@ -102,7 +102,7 @@ func RewardsV1(rewards []interface{}) ([]*types.Operation, *types.Error) {
 	return rewardOps, nil
 }
 
-func CreateHTLCV1(payer string, amount int64, fee *Fee, metadata map[string]interface{}) ([]*types.Operation, *types.Error) {
+func CreateHTLCV1(payer, address string, amount int64, fee *Fee, metadata map[string]interface{}) ([]*types.Operation, *types.Error) {
 	var CreateHTLCOps []*types.Operation
 
 	createHTLCOps, chErr := CreateDebitOp(CreateHTLCOp, payer, amount, HNT, SuccessStatus, 0, metadata)
@@ -110,30 +110,47 @@ func CreateHTLCV1(payer string, amount int64, fee *Fee, metadata map[string]inte
 		return nil, chErr
 	}
 
-	Fee, fErr := CreateFeeOp(payer, fee, SuccessStatus, 1, map[string]interface{}{})
+	escrowOp, eErr := CreateCreditOp(CreateHTLCOp, address, amount, HNT, SuccessStatus, 1, map[string]interface{}{"credit_category": "escrow"})
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	Fee, fErr := CreateFeeOp(payer, fee, SuccessStatus, 2, map[string]interface{}{})
 	if fErr != nil {
 		return nil, fErr
 	}
 
-	CreateHTLCOps = append(CreateHTLCOps, createHTLCOps, Fee)
+	CreateHTLCOps = append(CreateHTLCOps, createHTLCOps, escrowOp, Fee)
 
 	return CreateHTLCOps, nil
 }
 
-func RedeemHTLCV1(payee string, amount int64, fee *Fee, metadata map[string]interface{}) ([]*types.Operation, *types.Error) {
+// RedeemHTLCV1 moves the escrowed amount out of the HTLC address. A redeem
+// submitted by the original payer after the timelock is a refund.
+func RedeemHTLCV1(payee, payer, address string, amount int64, fee *Fee, metadata map[string]interface{}) ([]*types.Operation, *types.Error) {
 	var RedeemHTLCOps []*types.Operation
 
-	redeemHTLCOps, rhErr := CreateCreditOp(RedeemHTLCOp, payee, amount, HNT, SuccessStatus, 0, metadata)
+	opType := RedeemHTLCOp
+	if payee == payer {
+		opType = RefundHTLCOp
+	}
+
+	escrowOp, eErr := CreateDebitOp(opType, address, amount, HNT, SuccessStatus, 0, map[string]interface{}{"debit_category": "escrow"})
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	redeemHTLCOps, rhErr := CreateCreditOp(opType, payee, amount, HNT, SuccessStatus, 1, metadata)
 	if rhErr != nil {
 		return nil, rhErr
 	}
 
-	Fee, fErr := CreateFeeOp(payee, fee, SuccessStatus, 1, map[string]interface{}{})
+	Fee, fErr := CreateFeeOp(payee, fee, SuccessStatus, 2, map[string]interface{}{})
 	if fErr != nil {
 		return nil, fErr
 	}
 
-	RedeemHTLCOps = append(RedeemHTLCOps, redeemHTLCOps, Fee)
+	RedeemHTLCOps = append(RedeemHTLCOps, escrowOp, redeemHTLCOps, Fee)
 
 	return RedeemHTLCOps, nil
 }
@@ -300,8 +317,10 @@ func TransferHotspotV1(
 }
 
 func TokenBurnV1(
-	payer string,
+	payer,
+	payee string,
 	amount int64,
+	dcAmount int64,
 	fee *Fee,
 	metadata map[string]interface{},
 ) ([]*types.Operation, *types.Error) {
@@ -310,17 +329,73 @@ func TokenBurnV1(
 		return nil, tErr
 	}
 
-	Fee, fErr := CreateFeeOp(payer, fee, SuccessStatus, 1, map[string]interface{}{})
+	DCCredit, dErr := CreateCreditOp(TokenBurnOp, payee, dcAmount, DC, SuccessStatus, 1, map[string]interface{}{"credit_category": "token_burn"})
+	if dErr != nil {
+		return nil, dErr
+	}
+
+	Fee, fErr := CreateFeeOp(payer, fee, SuccessStatus, 2, map[string]interface{}{})
 	if fErr != nil {
 		return nil, fErr
 	}
 
 	return []*types.Operation{
 		TokenBurn,
+		DCCredit,
 		Fee,
 	}, nil
 }
 
+func DCCoinbaseV1(payee string, amount int64) ([]*types.Operation, *types.Error) {
+	DCCoinbase, cbErr := CreateCreditOp(DCCoinbaseOp, payee, amount, DC, SuccessStatus, 0, map[string]interface{}{"credit_category": "dc_coinbase"})
+	if cbErr != nil {
+		return nil, cbErr
+	}
+
+	return []*types.Operation{
+		DCCoinbase,
+	}, nil
+}
+
+func StateChannelOpenV1(
+	owner string,
+	amount int64,
+	fee *Fee,
+	metadata map[string]interface{},
+) ([]*types.Operation, *types.Error) {
+	StateChannelOpen, sErr := CreateDebitOp(StateChannelOpenOp, owner, amount, DC, SuccessStatus, 0, metadata)
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	Fee, fErr := CreateFeeOp(owner, fee, SuccessStatus, 1, map[string]interface{}{})
+	if fErr != nil {
+		return nil, fErr
+	}
+
+	return []*types.Operation{
+		StateChannelOpen,
+		Fee,
+	}, nil
+}
+
+// StateChannelCloseV1 refunds the owner whatever part of the DC committed at
+// open was not spent on the packets summarised in the closed channel.
+func StateChannelCloseV1(
+	owner string,
+	refund int64,
+	metadata map[string]interface{},
+) ([]*types.Operation, *types.Error) {
+	StateChannelClose, sErr := CreateCreditOp(StateChannelCloseOp, owner, refund, DC, SuccessStatus, 0, metadata)
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	return []*types.Operation{
+		StateChannelClose,
+	}, nil
+}
+
 func StakeValidatorV1(
 	owner string,
 	stake int64,
@@ -332,57 +407,113 @@ func StakeValidatorV1(
 		return nil, sErr
 	}
 
-	Fee, fErr := CreateFeeOp(owner, fee, SuccessStatus, 1, map[string]interface{}{})
+	Staked, stErr := CreateSubAccountCreditOp(StakeValidatorOp, owner, StakedSubAccount, stake, HNT, SuccessStatus, 1, map[string]interface{}{"credit_category": "stake"})
+	if stErr != nil {
+		return nil, stErr
+	}
+
+	Fee, fErr := CreateFeeOp(owner, fee, SuccessStatus, 2, map[string]interface{}{})
 	if fErr != nil {
 		return nil, fErr
 	}
 
 	return []*types.Operation{
 		StakeValidator,
+		Staked,
 		Fee,
 	}, nil
 }
 
-func UnstakeValidatorV1(
+// UnstakeReleaseOps returns the operations applied at stake_release_height,
+// moving the unstaked HNT out of the owner's cooldown sub-account and back
+// into its liquid balance.
+func UnstakeReleaseOps(
 	owner string,
 	stake int64,
-	stakeReleaseHeight int64,
-	fee *Fee,
 	metadata map[string]interface{},
 ) ([]*types.Operation, *types.Error) {
+	Cooldown, cErr := CreateSubAccountDebitOp(UnstakeValidatorOp, owner, CooldownSubAccount, stake, HNT, SuccessStatus, 0, map[string]interface{}{"debit_category": "cooldown"})
+	if cErr != nil {
+		return nil, cErr
+	}
 
-	Unstake, uErr := CreateCreditOp(UnstakeValidatorOp, owner, stake, HNT, SuccessStatus, 0, metadata)
+	Unstake, uErr := CreateCreditOp(UnstakeValidatorOp, owner, stake, HNT, SuccessStatus, 1, metadata)
 	if uErr != nil {
 		return nil, uErr
 	}
 
-	gErr := utils.CreateGhostTxn(
-		&utils.GhostTxnKey{
-			Network: CurrentNetwork,
-			Block: &types.BlockIdentifier{
-				Index: stakeReleaseHeight,
-			},
-			Transaction: &types.TransactionIdentifier{
-				Hash: fmt.Sprint(metadata["hash"]),
-			},
-		},
-		&utils.GhostTxnMetadata{
-			Operations: []*types.Operation{
-				Unstake,
+	return []*types.Operation{
+		Cooldown,
+		Unstake,
+	}, nil
+}
+
+// CreateUnstakeGhostTxn stores the ghost transaction releasing an unstake's
+// stake at stakeReleaseHeight, and reports whether it was not stored yet.
+// unstakeBlock is the height of the unstake, or 0 if unknown.
+func CreateUnstakeGhostTxn(
+	owner string,
+	stake int64,
+	stakeReleaseHeight int64,
+	unstakeBlock int64,
+	metadata map[string]interface{},
+) (bool, *types.Error) {
+	releaseOps, rErr := UnstakeReleaseOps(owner, stake, metadata)
+	if rErr != nil {
+		return false, rErr
+	}
+
+	payload, mErr := json.Marshal(&utils.GhostTxnMetadata{
+		Operations:   releaseOps,
+		Metadata:     UnstakeFields(metadata),
+		UnstakeBlock: unstakeBlock,
+		RelatedTransactions: []*types.RelatedTransaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: fmt.Sprint(metadata["hash"]),
+				},
+				Direction: types.Backward,
 			},
 		},
-	)
+	})
+	if mErr != nil {
+		return false, WrapErr(ErrFailed, mErr)
+	}
+
+	return ScheduleDeferredEffect(utils.GhostTxnKind, stakeReleaseHeight, fmt.Sprint(metadata["hash"]), payload)
+}
+
+func UnstakeValidatorV1(
+	owner string,
+	stake int64,
+	stakeReleaseHeight int64,
+	unstakeBlock int64,
+	fee *Fee,
+	metadata map[string]interface{},
+) ([]*types.Operation, *types.Error) {
 
-	if gErr != nil && gErr != badger.ErrBannedKey {
-		return nil, WrapErr(ErrFailed, gErr)
+	if _, gErr := CreateUnstakeGhostTxn(owner, stake, stakeReleaseHeight, unstakeBlock, metadata); gErr != nil {
+		return nil, gErr
 	}
 
-	Fee, fErr := CreateFeeOp(owner, fee, SuccessStatus, 0, map[string]interface{}{})
+	Staked, sErr := CreateSubAccountDebitOp(UnstakeValidatorOp, owner, StakedSubAccount, stake, HNT, SuccessStatus, 0, map[string]interface{}{"debit_category": "unstake"})
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	Cooldown, cErr := CreateSubAccountCreditOp(UnstakeValidatorOp, owner, CooldownSubAccount, stake, HNT, SuccessStatus, 1, map[string]interface{}{"credit_category": "cooldown"})
+	if cErr != nil {
+		return nil, cErr
+	}
+
+	Fee, fErr := CreateFeeOp(owner, fee, SuccessStatus, 2, map[string]interface{}{})
 	if fErr != nil {
 		return nil, fErr
 	}
 
 	return []*types.Operation{
+		Staked,
+		Cooldown,
 		Fee,
 	}, nil
 }
@@ -391,6 +522,7 @@ func TransferValidatorStakeV1(
 	newOwner,
 	oldOwner string,
 	paymentAmount int64,
+	stakeAmount int64,
 	fee *Fee,
 	metadata map[string]interface{},
 ) ([]*types.Operation, *types.Error) {
@@ -403,6 +535,22 @@ func TransferValidatorStakeV1(
 	index++
 	ops = append(ops, TransferValidator)
 
+	if newOwner != oldOwner && stakeAmount > int64(0) {
+		StakeDebit, sdErr := CreateSubAccountDebitOp(TransferValidatorStakeOp, oldOwner, StakedSubAccount, stakeAmount, HNT, SuccessStatus, index, map[string]interface{}{"debit_category": "stake_transfer"})
+		if sdErr != nil {
+			return nil, sdErr
+		}
+		index++
+
+		StakeCredit, scErr := CreateSubAccountCreditOp(TransferValidatorStakeOp, newOwner, StakedSubAccount, stakeAmount, HNT, SuccessStatus, index, map[string]interface{}{"credit_category": "stake_transfer"})
+		if scErr != nil {
+			return nil, scErr
+		}
+		index++
+
+		ops = append(ops, StakeDebit, StakeCredit)
+	}
+
 	if paymentAmount > int64(0) {
 		Debit, dErr := CreateDebitOp(DebitOp, newOwner, paymentAmount, HNT, SuccessStatus, index, map[string]interface{}{})
 		if dErr != nil {
@@ -429,6 +577,21 @@ func TransferValidatorStakeV1(
 	return ops, nil
 }
 
+func GenValidatorV1(
+	owner string,
+	stake int64,
+	metadata map[string]interface{},
+) ([]*types.Operation, *types.Error) {
+	Staked, sErr := CreateSubAccountCreditOp(StakeValidatorOp, owner, StakedSubAccount, stake, HNT, SuccessStatus, 0, metadata)
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	return []*types.Operation{
+		Staked,
+	}, nil
+}
+
 func FeeOnlyTxn(
 	opType,
 	payer,
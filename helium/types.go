@@ -39,6 +39,11 @@ const (
 	// NodeVersion is the version of helium we are using.
 	NodeVersion = "1.1.23"
 
+	// ParserVersion is the version of the block and transaction
+	// parsing in this package. Bump it whenever TransactionToOps
+	// changes so that stored blocks are rebuilt.
+	ParserVersion = 8
+
 	// Blockchain is Helium.
 	Blockchain string = "Helium"
 
@@ -230,6 +235,21 @@ const (
 	// for account reconciliation
 	GhostTxn = "ghost_txn"
 
+	// HTLCTimeoutTxn is used to describe
+	// an HTLC reaching its timelock without being
+	// redeemed (a deferred transaction)
+	HTLCTimeoutTxn = "htlc_timeout"
+
+	// StateChannelExpiryTxn is used to describe
+	// a state channel reaching its expiry height
+	// without being closed (a deferred transaction)
+	StateChannelExpiryTxn = "state_channel_expiry"
+
+	// StakeTransferCooldownTxn is used to describe
+	// the end of the cooldown of a validator whose
+	// stake was transferred (a deferred transaction)
+	StakeTransferCooldownTxn = "stake_transfer_cooldown"
+
 	// CoinbaseOp is used to describe
 	// the coinbase transaction at genesis (testnet only)
 	CoinbaseOp = "coinbase_op"
@@ -282,10 +302,30 @@ const (
 	// transferring a validator to a new owner and/or address
 	TransferValidatorStakeOp = "transfer_validator_op"
 
+	// HTLCTimeoutOp is used to describe
+	// an HTLC becoming refundable to its payer
+	HTLCTimeoutOp = "htlc_timeout_op"
+
+	// StateChannelExpiryOp is used to describe
+	// a state channel expiring
+	StateChannelExpiryOp = "state_channel_expiry_op"
+
+	// StakeTransferCooldownOp is used to describe
+	// the cooldown of a transferred validator ending
+	StakeTransferCooldownOp = "stake_transfer_cooldown_op"
+
 	// StateChannelOpenOp is used to describe
 	// opening a state channel
 	StateChannelOpenOp = "state_channel_open_op"
 
+	// StateChannelCloseOp is used to describe
+	// closing a state channel
+	StateChannelCloseOp = "state_channel_close_op"
+
+	// DCCoinbaseOp is used to describe
+	// DC granted to bring initial miners online
+	DCCoinbaseOp = "dc_coinbase_op"
+
 	// OUIOp is used to describe
 	// creating a new OUI
 	OUIOp = "oui_op"
@@ -294,10 +334,14 @@ const (
 	// creating an HTLC transaction
 	CreateHTLCOp = "create_htlc_op"
 
-	// CreateHTLCOp is used to describe
-	// creating an HTLC transaction
+	// RedeemHTLCOp is used to describe
+	// redeeming an HTLC transaction
 	RedeemHTLCOp = "redeem_htlc_op"
 
+	// RefundHTLCOp is used to describe
+	// the payer reclaiming a timed-out HTLC
+	RefundHTLCOp = "refund_htlc_op"
+
 	// UpdateGatewayOUIOp is used to describe
 	// updating a gateway's OUI
 	UpdateGatewayOUIOp = "update_gateway_oui_op"
@@ -314,8 +358,65 @@ const (
 	// genesis block for blockchain-etl instances
 	GenesisBlockIndex = int64(1)
 
+	// IrreversibleDepth is how many blocks below the node's tip a block
+	// has to be before it is stored or indexed. Blocks closer to the tip
+	// could still be replaced by the node, so they are rebuilt on every
+	// request instead.
+	IrreversibleDepth = int64(3)
+
 	// IncludeMempoolCoins does not apply to rosetta-ethereum as it is not UTXO-based.
 	IncludeMempoolCoins = false
+
+	// StakedSubAccount is the sub-account holding
+	// HNT an owner has staked to its validators
+	StakedSubAccount = "staked"
+
+	// CooldownSubAccount is the sub-account holding
+	// unstaked HNT waiting for its stake_release_height
+	CooldownSubAccount = "cooldown"
+
+	// WalletAddress is the address_kind of
+	// a plain account
+	WalletAddress = "wallet"
+
+	// GatewayAddress is the address_kind of
+	// a gateway (hotspot)
+	GatewayAddress = "gateway"
+
+	// ValidatorAddress is the address_kind of
+	// a validator
+	ValidatorAddress = "validator"
+
+	// HTLCAddress is the address_kind of
+	// a hashed timelock
+	HTLCAddress = "htlc"
+
+	// OraclePriceCall returns the HNT oracle
+	// price at a height
+	OraclePriceCall = "oracle_price"
+
+	// HTLCCall returns an HTLC's receipt
+	HTLCCall = "htlc"
+
+	// GatewayInfoCall returns a gateway's
+	// on-chain record at a height
+	GatewayInfoCall = "gateway_info"
+
+	// AccountNonceCall returns an account's
+	// current payment nonce
+	AccountNonceCall = "account_nonce"
+
+	// ChainVarsCall returns the current
+	// chain variables
+	ChainVarsCall = "chain_vars"
+
+	// ValidatorInfoCall returns a validator's
+	// on-chain record at a height
+	ValidatorInfoCall = "validator_info"
+
+	// QuarantineCall lists the records moved
+	// into quarantine because they did not decode
+	QuarantineCall = "quarantined_records"
 )
 
 var (
@@ -415,6 +516,7 @@ var (
 		CreditOp,
 		DebitOp,
 		RedeemHTLCOp,
+		RefundHTLCOp,
 		RewardOp,
 		RoutingOp,
 		FeeOp,
@@ -423,7 +525,12 @@ var (
 		StakeValidatorOp,
 		UnstakeValidatorOp,
 		TransferValidatorStakeOp,
+		HTLCTimeoutOp,
+		StateChannelExpiryOp,
+		StakeTransferCooldownOp,
 		StateChannelOpenOp,
+		StateChannelCloseOp,
+		DCCoinbaseOp,
 		OUIOp,
 		PassthroughOp,
 		UpdateGatewayOUIOp,
@@ -445,16 +552,52 @@ var (
 		},
 	}
 
+	// CallMethods are all methods supported by /call.
+	CallMethods = []string{
+		OraclePriceCall,
+		HTLCCall,
+		GatewayInfoCall,
+		AccountNonceCall,
+		ChainVarsCall,
+		ValidatorInfoCall,
+		QuarantineCall,
+	}
+
 	// LBS is the LastBlessedBlock height as an int64
 	LBS = readLBSfile()
 )
 
 type Block struct {
-	Hash         string                   `json:"hash"`
-	Height       int64                    `json:"height"`
-	PrevHash     string                   `json:"prev_hash"`
-	Time         int64                    `json:"time"`
-	Transactions []map[string]interface{} `json:"transactions"`
+	ElectionEpoch *int64                   `json:"election_epoch"`
+	Hash          string                   `json:"hash"`
+	Height        int64                    `json:"height"`
+	PrevHash      string                   `json:"prev_hash"`
+	Time          int64                    `json:"time"`
+	Transactions  []map[string]interface{} `json:"transactions"`
+}
+
+type BlockHeader struct {
+	Hash     string `json:"hash"`
+	Height   int64  `json:"height"`
+	PrevHash string `json:"prev_hash"`
+	Time     int64  `json:"time"`
+}
+
+func (h *BlockHeader) BlockIdentifier() *types.BlockIdentifier {
+	return &types.BlockIdentifier{
+		Index: h.Height,
+		Hash:  h.Hash,
+	}
+}
+
+// BlockTimestamp converts a block time in seconds into the millisecond
+// timestamp Rosetta expects. Blocks without a time (genesis) are pinned
+// to 2000-01-01.
+func BlockTimestamp(blockTime int64) int64 {
+	if blockTime == 0 {
+		blockTime = 946684800
+	}
+	return blockTime * 1000
 }
 
 type Peer struct {
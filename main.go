@@ -15,15 +15,13 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/helium/rosetta-helium/helium"
 	"github.com/helium/rosetta-helium/services"
@@ -39,6 +37,8 @@ import (
 
 const (
 	serverPort = 8080
+
+	indexerPollInterval = 10 * time.Second
 )
 
 // NewBlockchainRouter creates a Mux http.Handler from a collection
@@ -46,14 +46,16 @@ const (
 func NewBlockchainRouter(
 	network *types.NetworkIdentifier,
 	a *asserter.Asserter,
+	indexer *helium.Indexer,
+	maxTransactionOps int,
 ) http.Handler {
-	networkAPIService := services.NewNetworkAPIService(network)
+	networkAPIService := services.NewNetworkAPIService(network, indexer)
 	networkAPIController := server.NewNetworkAPIController(
 		networkAPIService,
 		a,
 	)
 
-	blockAPIService := services.NewBlockAPIService(network)
+	blockAPIService := services.NewBlockAPIService(network, maxTransactionOps)
 	blockAPIController := server.NewBlockAPIController(
 		blockAPIService,
 		a,
@@ -71,70 +73,33 @@ func NewBlockchainRouter(
 		a,
 	)
 
-	return server.NewRouter(networkAPIController, blockAPIController, accountAPIController, constructionAPIController)
-}
-
-func LoadGhostTxns(network *types.NetworkIdentifier, db *badger.DB) error {
-	if werr := filepath.Walk("ghost-transactions", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			zap.S().Error(err.Error())
-		}
-		if info.IsDir() {
-			return nil
-		}
-
-		jsonFile, jerr := os.Open("ghost-transactions/" + info.Name())
-		if jerr != nil {
-			return jerr
-		}
-		defer jsonFile.Close()
-
-		byteValue, _ := ioutil.ReadAll(jsonFile)
-
-		var result []map[string]interface{}
-		json.Unmarshal([]byte(byteValue), &result)
-
-		for _, txn := range result {
-			var txnMetadata helium.UnstakeTransaction
-			var txnMetadataMap map[string]interface{}
-			json.Unmarshal([]byte(fmt.Sprint(txn["Fields"])), &txnMetadata)
-			json.Unmarshal([]byte(fmt.Sprint(txn["Fields"])), &txnMetadataMap)
-
-			dbKey := &utils.GhostTxnKey{
-				Network: network,
-				Block: &types.BlockIdentifier{
-					Index: txnMetadata.StakeReleaseHeight,
-				},
-				Transaction: &types.TransactionIdentifier{
-					Hash: txnMetadata.Hash,
-				},
-			}
-
-			Unstake, uErr := helium.CreateCreditOp(helium.UnstakeValidatorOp, txnMetadata.Address, txnMetadata.StakeAmount, helium.HNT, helium.SuccessStatus, 0, txnMetadataMap)
-			if uErr != nil {
-				return errors.New(fmt.Sprint(uErr))
-			}
-
-			cerr := utils.CreateGhostTxn(dbKey, &utils.GhostTxnMetadata{
-				Operations: []*types.Operation{
-					Unstake,
-				},
-				Metadata: txnMetadataMap,
-			})
-
-			if cerr != nil && cerr != badger.ErrBannedKey {
-				return cerr
-			}
-		}
+	searchAPIService := services.NewSearchAPIService(network)
+	searchAPIController := server.NewSearchAPIController(
+		searchAPIService,
+		a,
+	)
 
-		zap.S().Info("Loaded " + fmt.Sprint(len(result)) + " ghost txns from file " + info.Name())
-		return nil
+	eventsAPIService := services.NewEventsAPIService(network)
+	eventsAPIController := server.NewEventsAPIController(
+		eventsAPIService,
+		a,
+	)
 
-	}); werr != nil {
-		return werr
-	}
+	callAPIService := services.NewCallAPIService(network)
+	callAPIController := server.NewCallAPIController(
+		callAPIService,
+		a,
+	)
 
-	return nil
+	return server.NewRouter(
+		networkAPIController,
+		blockAPIController,
+		accountAPIController,
+		constructionAPIController,
+		searchAPIController,
+		eventsAPIController,
+		callAPIController,
+	)
 }
 
 func main() {
@@ -152,10 +117,37 @@ func main() {
 
 	utils.DB = bdb
 
+	migrated, merr := utils.Migrate(helium.RebuildLegacyGhostTxn)
+	for _, line := range migrated {
+		zap.S().Info("Applied " + line)
+	}
+	if merr != nil {
+		zap.S().Fatal("Cannot migrate the badger store: " + merr.Error())
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "ghost" {
+		code := runGhostCommand(os.Args[2:], os.Stdout, os.Stderr)
+		bdb.Close()
+		os.Exit(code)
+	}
+
 	var testnet bool
+	var index bool
+	var backfill bool
+	var maxTransactionOps int
+	var ghostDir string
+	var ghostBackfillFrom int64
+	var ghostBackfillTo int64
 	var network *types.NetworkIdentifier
 
 	flag.BoolVar(&testnet, "testnet", false, "run testnet version of rosetta-helium")
+	flag.BoolVar(&index, "index", true, "build new blocks into the local block store in the background")
+	flag.BoolVar(&backfill, "backfill", false, "start indexing from the last blessed snapshot instead of the current tip")
+	flag.IntVar(&maxTransactionOps, "max-txn-ops", 0, "return transactions with more operations than this as other_transactions in /block (0 disables)")
+	flag.IntVar(&helium.TransactionWorkers, "txn-workers", helium.TransactionWorkers, "number of transactions fetched concurrently per block")
+	flag.StringVar(&ghostDir, "ghost-dir", "ghost-transactions", "directory holding the mainnet/ and testnet/ ghost transaction files")
+	flag.Int64Var(&ghostBackfillFrom, "ghost-backfill-from", 0, "before serving, scan blocks from this height for unstakes and store their ghost transactions (0 disables)")
+	flag.Int64Var(&ghostBackfillTo, "ghost-backfill-to", 0, "last block scanned by --ghost-backfill-from (0 is the current height)")
 	flag.Parse()
 
 	if !testnet {
@@ -164,12 +156,6 @@ func main() {
 			Blockchain: "Helium",
 			Network:    helium.MainnetNetwork,
 		}
-
-		if lerr := LoadGhostTxns(network, bdb); lerr != nil {
-			zap.S().Error("Cannot load ghost transactions: " + lerr.Error())
-			os.Exit(1)
-		}
-
 	} else {
 		zap.S().Info("Initilizing testnet node...")
 		network = &types.NetworkIdentifier{
@@ -180,22 +166,51 @@ func main() {
 
 	helium.CurrentNetwork = network
 
+	if lerr := helium.LoadGhostTxns(ghostDir, network); lerr != nil {
+		zap.S().Error("Cannot load ghost transactions: " + lerr.Error())
+		os.Exit(1)
+	}
+
+	if ghostBackfillFrom > 0 {
+		if ghostBackfillTo == 0 {
+			currentHeight, chErr := helium.GetCurrentHeight()
+			if chErr != nil {
+				zap.S().Error("Cannot get current height for ghost backfill: " + chErr.Message)
+				os.Exit(1)
+			}
+			ghostBackfillTo = *currentHeight
+		}
+
+		report, berr := helium.BackfillGhostTxns(context.Background(), ghostBackfillFrom, ghostBackfillTo)
+		if berr != nil {
+			zap.S().Error("Ghost backfill failed: " + berr.Error())
+			os.Exit(1)
+		}
+		zap.S().Info("Ghost backfill " + report.String())
+	}
+
 	// The asserter automatically rejects incorrectly formatted
 	// requests.
 	a, err := asserter.NewServer(
 		helium.OperationTypes,
 		helium.HistoricalBalanceSupported,
 		[]*types.NetworkIdentifier{network},
-		nil,
-		false,
+		helium.CallMethods,
+		helium.IncludeMempoolCoins,
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	var indexer *helium.Indexer
+	if index {
+		indexer = helium.NewIndexer(indexerPollInterval, backfill)
+		go indexer.Run(context.Background())
+	}
+
 	// Create the main router handler then apply the logger and Cors
 	// middlewares in sequence.
-	router := NewBlockchainRouter(network, a)
+	router := NewBlockchainRouter(network, a, indexer, maxTransactionOps)
 	loggedRouter := server.LoggerMiddleware(router)
 	corsRouter := server.CorsMiddleware(loggedRouter)
 	zap.S().Info("Listening on port ", serverPort)
@@ -17,6 +17,7 @@ package services
 import (
 	"context"
 	"errors"
+	"strconv"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -45,34 +46,6 @@ func (s *AccountAPIService) AccountBalance(
 		Address: request.AccountIdentifier.Address,
 	}
 
-	if request.BlockIdentifier != nil {
-		if request.BlockIdentifier.Index == nil {
-			return nil, helium.WrapErr(
-				helium.ErrInvalidParameter,
-				errors.New("request.BlockIdentifier requires an Index"),
-			)
-		}
-		balanceRequest.Height = *request.BlockIdentifier.Index
-	}
-
-	accountBalances, aErr := helium.GetBalance(balanceRequest)
-	if aErr != nil {
-		if aErr.Code == 1 {
-			accountBalances = []*types.Amount{
-				{
-					Value:    "0",
-					Currency: helium.HNT,
-				},
-				{
-					Value:    "0",
-					Currency: helium.HST,
-				},
-			}
-		} else {
-			return nil, aErr
-		}
-	}
-
 	var blockId types.BlockIdentifier
 
 	if request.BlockIdentifier == nil {
@@ -81,34 +54,91 @@ func (s *AccountAPIService) AccountBalance(
 			return nil, chErr
 		}
 
-		currentBlock, cErr := helium.GetBlock(&types.PartialBlockIdentifier{
+		currentBlock, cErr := helium.GetBlockHeader(&types.PartialBlockIdentifier{
 			Index: currentHeight,
 		})
 		if cErr != nil {
 			return nil, cErr
 		}
 
-		blockId = types.BlockIdentifier{
-			Index: currentBlock.BlockIdentifier.Index,
-			Hash:  currentBlock.BlockIdentifier.Hash,
-		}
+		blockId = *currentBlock.BlockIdentifier()
 	} else {
-		requestedBlock, rErr := helium.GetBlock(&types.PartialBlockIdentifier{
+		if request.BlockIdentifier.Index == nil && request.BlockIdentifier.Hash == nil {
+			return nil, helium.WrapErr(
+				helium.ErrInvalidParameter,
+				errors.New("request.BlockIdentifier requires an Index or a Hash"),
+			)
+		}
+
+		requestedBlock, rErr := helium.GetBlockHeader(&types.PartialBlockIdentifier{
 			Index: request.BlockIdentifier.Index,
+			Hash:  request.BlockIdentifier.Hash,
 		})
 		if rErr != nil {
 			return nil, rErr
 		}
 
-		blockId = types.BlockIdentifier{
-			Index: requestedBlock.BlockIdentifier.Index,
-			Hash:  requestedBlock.BlockIdentifier.Hash,
+		if request.BlockIdentifier.Hash != nil && requestedBlock.Hash != *request.BlockIdentifier.Hash {
+			return nil, helium.WrapErr(
+				helium.ErrInvalidParameter,
+				errors.New("ambiguous request: requested block height ("+
+					strconv.FormatInt(requestedBlock.Height, 10)+
+					") does not match requested block hash ("+
+					*request.BlockIdentifier.Hash+
+					")"),
+			)
+		}
+
+		blockId = *requestedBlock.BlockIdentifier()
+
+		balanceRequest.Height = blockId.Index
+	}
+
+	account, aErr := helium.GetAccount(balanceRequest)
+	if aErr != nil && aErr.Code != 1 {
+		return nil, aErr
+	}
+
+	if request.AccountIdentifier.SubAccount == nil {
+		htlcBalances, hErr := helium.GetHTLCBalance(request.AccountIdentifier.Address, account, blockId.Index)
+		if hErr == nil {
+			return &types.AccountBalanceResponse{
+				BlockIdentifier: &blockId,
+				Balances:        htlcBalances,
+				Metadata: map[string]interface{}{
+					"address_kind": helium.HTLCAddress,
+				},
+			}, nil
+		} else if hErr.Code != 1 {
+			return nil, hErr
 		}
 	}
 
+	if account == nil {
+		account = &helium.GetBalanceResponse{
+			Address: request.AccountIdentifier.Address,
+		}
+	}
+
+	accountBalances := helium.AccountBalances(account)
+
+	if request.AccountIdentifier.SubAccount != nil {
+		subAccountBalances, sErr := helium.SubAccountBalances(account, request.AccountIdentifier.SubAccount.Address)
+		if sErr != nil {
+			return nil, sErr
+		}
+		accountBalances = subAccountBalances
+	}
+
+	accountMetadata, mErr := helium.GetAccountMetadata(account, blockId.Index)
+	if mErr != nil {
+		return nil, mErr
+	}
+
 	return &types.AccountBalanceResponse{
 		BlockIdentifier: &blockId,
 		Balances:        accountBalances,
+		Metadata:        accountMetadata,
 	}, nil
 }
 
@@ -26,13 +26,17 @@ import (
 
 // BlockAPIService implements the server.BlockAPIServicer interface.
 type BlockAPIService struct {
-	network *types.NetworkIdentifier
+	network           *types.NetworkIdentifier
+	maxTransactionOps int
 }
 
 // NewBlockAPIService creates a new instance of a BlockAPIService.
-func NewBlockAPIService(network *types.NetworkIdentifier) server.BlockAPIServicer {
+// Transactions with more than maxTransactionOps operations are returned
+// as OtherTransactions (0 disables this).
+func NewBlockAPIService(network *types.NetworkIdentifier, maxTransactionOps int) server.BlockAPIServicer {
 	return &BlockAPIService{
-		network: network,
+		network:           network,
+		maxTransactionOps: maxTransactionOps,
 	}
 }
 
@@ -41,49 +45,65 @@ func (s *BlockAPIService) Block(
 	ctx context.Context,
 	request *types.BlockRequest,
 ) (*types.BlockResponse, *types.Error) {
-	previousBlockIndex := *request.BlockIdentifier.Index - 1
-	if previousBlockIndex == 0 {
-		previousBlockIndex = 1
+	if request.BlockIdentifier == nil {
+		return nil, helium.WrapErr(helium.ErrInvalidParameter, errors.New("request.BlockIdentifier is required"))
 	}
 
-	requestedBlock, rErr := helium.GetBlock(request.BlockIdentifier)
-	if rErr != nil {
-		return nil, rErr
+	blockIdentifier := request.BlockIdentifier
+
+	// An empty PartialBlockIdentifier requests the latest block
+	if blockIdentifier.Index == nil && blockIdentifier.Hash == nil {
+		currentHeight, chErr := helium.GetCurrentHeight()
+		if chErr != nil {
+			return nil, chErr
+		}
+
+		blockIdentifier = &types.PartialBlockIdentifier{
+			Index: currentHeight,
+		}
 	}
 
-	previousBlock, pErr := helium.GetBlock(&types.PartialBlockIdentifier{
-		Index: &previousBlockIndex,
-	})
-	if pErr != nil {
-		return nil, pErr
+	requestedBlock, rErr := helium.GetBlock(blockIdentifier)
+	if rErr != nil {
+		return nil, rErr
 	}
 
-	if request.BlockIdentifier.Hash != nil {
-		if requestedBlock.BlockIdentifier.Hash != *request.BlockIdentifier.Hash {
+	if blockIdentifier.Hash != nil {
+		if requestedBlock.BlockIdentifier.Hash != *blockIdentifier.Hash {
 			return nil, helium.WrapErr(
 				helium.ErrNotFound,
 				errors.New("ambiguous request: requested block height ("+
-					strconv.FormatInt(*request.BlockIdentifier.Index, 10)+
+					strconv.FormatInt(requestedBlock.BlockIdentifier.Index, 10)+
 					") does not match returned block hash ("+
-					requestedBlock.BlockIdentifier.Hash+
+					*blockIdentifier.Hash+
 					")"),
 			)
 		}
 	}
 
+	if s.maxTransactionOps <= 0 {
+		return &types.BlockResponse{
+			Block: requestedBlock,
+		}, nil
+	}
+
+	// Leave oversized transactions (usually rewards) to /block/transaction
+	// so that the block itself stays small enough to fetch.
+	block := *requestedBlock
+	block.Transactions = nil
+	var otherTransactions []*types.TransactionIdentifier
+
+	for _, txn := range requestedBlock.Transactions {
+		if len(txn.Operations) > s.maxTransactionOps {
+			otherTransactions = append(otherTransactions, txn.TransactionIdentifier)
+			continue
+		}
+		block.Transactions = append(block.Transactions, txn)
+	}
+
 	return &types.BlockResponse{
-		Block: &types.Block{
-			BlockIdentifier: &types.BlockIdentifier{
-				Index: *request.BlockIdentifier.Index,
-				Hash:  requestedBlock.BlockIdentifier.Hash,
-			},
-			ParentBlockIdentifier: &types.BlockIdentifier{
-				Index: previousBlockIndex,
-				Hash:  previousBlock.BlockIdentifier.Hash,
-			},
-			Timestamp:    requestedBlock.Timestamp,
-			Transactions: requestedBlock.Transactions,
-		},
+		Block:             &block,
+		OtherTransactions: otherTransactions,
 	}, nil
 }
 
@@ -92,7 +112,7 @@ func (s *BlockAPIService) BlockTransaction(
 	ctx context.Context,
 	request *types.BlockTransactionRequest,
 ) (*types.BlockTransactionResponse, *types.Error) {
-	txn, txErr := helium.GetTransaction(request.TransactionIdentifier.Hash, request.BlockIdentifier)
+	txn, txErr := helium.GetBlockTransaction(request.TransactionIdentifier.Hash, request.BlockIdentifier)
 	if txErr != nil {
 		return nil, txErr
 	}
@@ -0,0 +1,81 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/helium"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+const (
+	defaultEventsLimit = int64(100)
+	maxEventsLimit     = int64(1000)
+)
+
+// EventsAPIService implements the server.EventsAPIServicer interface.
+type EventsAPIService struct {
+	network *types.NetworkIdentifier
+}
+
+// NewEventsAPIService creates a new instance of an EventsAPIService.
+func NewEventsAPIService(network *types.NetworkIdentifier) server.EventsAPIServicer {
+	return &EventsAPIService{
+		network: network,
+	}
+}
+
+// EventsBlocks implements the /events/blocks endpoint. Events are recorded
+// by the block indexer, so the log is empty when it is disabled.
+func (s *EventsAPIService) EventsBlocks(
+	ctx context.Context,
+	request *types.EventsBlocksRequest,
+) (*types.EventsBlocksResponse, *types.Error) {
+	offset := int64(0)
+	if request.Offset != nil {
+		if *request.Offset < 0 {
+			return nil, helium.WrapErr(helium.ErrInvalidParameter, errors.New("offset cannot be negative"))
+		}
+		offset = *request.Offset
+	}
+
+	limit := defaultEventsLimit
+	if request.Limit != nil {
+		if *request.Limit <= 0 {
+			return nil, helium.WrapErr(helium.ErrInvalidParameter, errors.New("limit must be positive"))
+		}
+		limit = *request.Limit
+	}
+	if limit > maxEventsLimit {
+		limit = maxEventsLimit
+	}
+
+	events, maxSequence, err := utils.GetBlockEvents(s.network, offset, limit)
+	if err == badger.ErrKeyNotFound {
+		return nil, helium.WrapErr(helium.ErrNotFound, errors.New("no block events have been recorded yet"))
+	} else if err != nil {
+		return nil, helium.WrapErr(helium.ErrFailed, err)
+	}
+
+	return &types.EventsBlocksResponse{
+		MaxSequence: maxSequence,
+		Events:      events,
+	}, nil
+}
@@ -27,12 +27,14 @@ import (
 // NetworkAPIService implements the server.NetworkAPIServicer interface.
 type NetworkAPIService struct {
 	network *types.NetworkIdentifier
+	indexer *helium.Indexer
 }
 
 // NewNetworkAPIService creates a new instance of a NetworkAPIService.
-func NewNetworkAPIService(network *types.NetworkIdentifier) server.NetworkAPIServicer {
+func NewNetworkAPIService(network *types.NetworkIdentifier, indexer *helium.Indexer) server.NetworkAPIServicer {
 	return &NetworkAPIService{
 		network: network,
+		indexer: indexer,
 	}
 }
 
@@ -58,7 +60,7 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, chErr
 	}
 
-	currentBlock, cbErr := helium.GetBlock(&types.PartialBlockIdentifier{
+	currentBlock, cbErr := helium.GetBlockHeader(&types.PartialBlockIdentifier{
 		Index: currentHeight,
 	})
 
@@ -66,11 +68,11 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, cbErr
 	}
 
-	if currentBlock.BlockIdentifier.Index < *helium.LBS {
+	if currentBlock.Height < *helium.LBS {
 		return nil, helium.WrapErr(helium.ErrNodeSync, errors.New("node is catching up to snapshot height"))
 	}
 
-	lastBlessedBlock, lbErr := helium.GetBlock(&types.PartialBlockIdentifier{
+	lastBlessedBlock, lbErr := helium.GetBlockHeader(&types.PartialBlockIdentifier{
 		Index: helium.LBS,
 	})
 
@@ -96,22 +98,23 @@ func (s *NetworkAPIService) NetworkStatus(
 		genesisHash = helium.TestnetGenesisBlockHash
 	}
 
+	// NetworkStatusResponse carries no metadata, so indexer progress is
+	// reported as the sync stage.
+	if s.indexer != nil {
+		stage := s.indexer.Stage()
+		syncStatus.Stage = &stage
+	}
+
 	return &types.NetworkStatusResponse{
-		CurrentBlockIdentifier: &types.BlockIdentifier{
-			Index: currentBlock.BlockIdentifier.Index,
-			Hash:  currentBlock.BlockIdentifier.Hash,
-		},
-		CurrentBlockTimestamp: currentBlock.Timestamp,
+		CurrentBlockIdentifier: currentBlock.BlockIdentifier(),
+		CurrentBlockTimestamp:  helium.BlockTimestamp(currentBlock.Time),
 		GenesisBlockIdentifier: &types.BlockIdentifier{
 			Index: genesisIndex,
 			Hash:  genesisHash,
 		},
-		OldestBlockIdentifier: &types.BlockIdentifier{
-			Index: lastBlessedBlock.BlockIdentifier.Index,
-			Hash:  lastBlessedBlock.BlockIdentifier.Hash,
-		},
-		Peers:      peers,
-		SyncStatus: syncStatus,
+		OldestBlockIdentifier: lastBlessedBlock.BlockIdentifier(),
+		Peers:                 peers,
+		SyncStatus:            syncStatus,
 	}, nil
 }
 
@@ -130,6 +133,7 @@ func (s *NetworkAPIService) NetworkOptions(
 			OperationTypes:          helium.OperationTypes,
 			OperationStatuses:       helium.OperationStatuses,
 			HistoricalBalanceLookup: helium.HistoricalBalanceSupported,
+			CallMethods:             helium.CallMethods,
 		},
 	}, nil
 }
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// Processed blocks are stored under keys that include the parser version,
+// so bumping the version makes every previously stored block unreachable and
+// forces it to be rebuilt.
+func blockHeightKey(network *types.NetworkIdentifier, version int, height int64) []byte {
+	return newStoreKey(blockNamespace).network(network).int64(int64(version)).int64(height)
+}
+
+func blockHashKey(network *types.NetworkIdentifier, version int, hash string) []byte {
+	return newStoreKey(blockHashNamespace).network(network).int64(int64(version)).str(hash)
+}
+
+func PutBlock(network *types.NetworkIdentifier, version int, block *types.Block) error {
+	blockBytes, merr := json.Marshal(block)
+	if merr != nil {
+		return merr
+	}
+
+	return DB.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(blockHeightKey(network, version, block.BlockIdentifier.Index), blockBytes); err != nil {
+			return err
+		}
+		return txn.Set(
+			blockHashKey(network, version, block.BlockIdentifier.Hash),
+			[]byte(strconv.FormatInt(block.BlockIdentifier.Index, 10)),
+		)
+	})
+}
+
+func getBlockAtKey(txn *badger.Txn, key []byte) (*types.Block, error) {
+	item, gerr := txn.Get(key)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	var block types.Block
+	ierr := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &block)
+	})
+	if ierr != nil {
+		return nil, ierr
+	}
+
+	return &block, nil
+}
+
+// GetBlockByHeight returns a stored block, or badger.ErrKeyNotFound if the
+// block has not been stored under this parser version.
+func GetBlockByHeight(network *types.NetworkIdentifier, version int, height int64) (*types.Block, error) {
+	var block *types.Block
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		b, err := getBlockAtKey(txn, blockHeightKey(network, version, height))
+		block = b
+		return err
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	return block, nil
+}
+
+// GetBlockByHash resolves hash through the hash index and returns the stored
+// block, or badger.ErrKeyNotFound.
+func GetBlockByHash(network *types.NetworkIdentifier, version int, hash string) (*types.Block, error) {
+	var block *types.Block
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(blockHashKey(network, version, hash))
+		if gerr != nil {
+			return gerr
+		}
+
+		heightBytes, cerr := item.ValueCopy(nil)
+		if cerr != nil {
+			return cerr
+		}
+
+		height, perr := strconv.ParseInt(string(heightBytes), 10, 64)
+		if perr != nil {
+			return perr
+		}
+
+		b, err := getBlockAtKey(txn, blockHeightKey(network, version, height))
+		block = b
+		return err
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	return block, nil
+}
+
+// DeleteBlock drops a stored block and its hash index entry, e.g. when a
+// ghost transaction is added at that height after the block was stored.
+func DeleteBlock(network *types.NetworkIdentifier, version int, height int64) error {
+	return DB.Update(func(txn *badger.Txn) error {
+		block, gerr := getBlockAtKey(txn, blockHeightKey(network, version, height))
+		if gerr == badger.ErrKeyNotFound {
+			return nil
+		} else if gerr != nil {
+			return gerr
+		}
+
+		if err := txn.Delete(blockHashKey(network, version, block.BlockIdentifier.Hash)); err != nil {
+			return err
+		}
+		return txn.Delete(blockHeightKey(network, version, height))
+	})
+}
+
+func indexedHeightKey(network *types.NetworkIdentifier, version int) []byte {
+	return newStoreKey(indexedHeightNamespace).network(network).int64(int64(version))
+}
+
+// GetIndexedHeight returns the last height the indexer stored under this
+// parser version, or badger.ErrKeyNotFound if it has not stored any.
+func GetIndexedHeight(network *types.NetworkIdentifier, version int) (int64, error) {
+	var height int64
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(indexedHeightKey(network, version))
+		if gerr != nil {
+			return gerr
+		}
+
+		heightBytes, cerr := item.ValueCopy(nil)
+		if cerr != nil {
+			return cerr
+		}
+
+		h, perr := strconv.ParseInt(string(heightBytes), 10, 64)
+		height = h
+		return perr
+	})
+	if verr != nil {
+		return 0, verr
+	}
+
+	return height, nil
+}
+
+func PutIndexedHeight(network *types.NetworkIdentifier, version int, height int64) error {
+	return DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(indexedHeightKey(network, version), []byte(strconv.FormatInt(height, 10)))
+	})
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+func testBlock(height int64, hash string) *types.Block {
+	return &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: height, Hash: hash},
+		ParentBlockIdentifier: &types.BlockIdentifier{Index: height - 1, Hash: "parent"},
+		Timestamp:             1600000000000,
+		Transactions:          []*types.Transaction{},
+	}
+}
+
+func TestBlockStore(t *testing.T) {
+	openTestDB(t)
+
+	if err := PutBlock(testNetwork, 1, testBlock(10, "h10")); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := GetBlockByHeight(testNetwork, 1, 10)
+	if err != nil || block.BlockIdentifier.Hash != "h10" {
+		t.Fatalf("by height: got %v, %v", block, err)
+	}
+
+	block, err = GetBlockByHash(testNetwork, 1, "h10")
+	if err != nil || block.BlockIdentifier.Index != 10 {
+		t.Fatalf("by hash: got %v, %v", block, err)
+	}
+
+	// A parser version bump makes stored blocks unreachable
+	if _, err := GetBlockByHeight(testNetwork, 2, 10); err != badger.ErrKeyNotFound {
+		t.Fatalf("by height after version bump: got %v, want ErrKeyNotFound", err)
+	}
+	if _, err := GetBlockByHash(testNetwork, 2, "h10"); err != badger.ErrKeyNotFound {
+		t.Fatalf("by hash after version bump: got %v, want ErrKeyNotFound", err)
+	}
+
+	if err := PutBlock(testNetwork, 2, testBlock(10, "h10")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteBlock(testNetwork, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetBlockByHeight(testNetwork, 1, 10); err != badger.ErrKeyNotFound {
+		t.Fatalf("by height after delete: got %v, want ErrKeyNotFound", err)
+	}
+	if _, err := GetBlockByHash(testNetwork, 1, "h10"); err != badger.ErrKeyNotFound {
+		t.Fatalf("by hash after delete: got %v, want ErrKeyNotFound", err)
+	}
+
+	// Deleting under one version leaves the other alone
+	if _, err := GetBlockByHeight(testNetwork, 2, 10); err != nil {
+		t.Fatalf("other version after delete: got %v", err)
+	}
+
+	// Deleting a block that is not stored is not an error
+	if err := DeleteBlock(testNetwork, 1, 11); err != nil {
+		t.Fatalf("delete missing block: got %v", err)
+	}
+}
+
+func TestIndexedHeight(t *testing.T) {
+	openTestDB(t)
+
+	if _, err := GetIndexedHeight(testNetwork, 1); err != badger.ErrKeyNotFound {
+		t.Fatalf("empty store: got %v, want ErrKeyNotFound", err)
+	}
+
+	if err := PutIndexedHeight(testNetwork, 1, 42); err != nil {
+		t.Fatal(err)
+	}
+	if height, err := GetIndexedHeight(testNetwork, 1); err != nil || height != 42 {
+		t.Fatalf("got %d, %v, want 42", height, err)
+	}
+	if _, err := GetIndexedHeight(testNetwork, 2); err != badger.ErrKeyNotFound {
+		t.Fatalf("after version bump: got %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+var testNetwork = &types.NetworkIdentifier{Blockchain: "Helium", Network: "Mainnet"}
+
+// openTestDB points DB at an in-memory badger for the duration of a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := DB
+	DB = db
+
+	t.Cleanup(func() {
+		db.Close()
+		DB = previous
+	})
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// DeferredEffect is a balance change that lands at Height, after the
+// transaction Source that caused it. Payload is interpreted by the effect's
+// kind.
+type DeferredEffect struct {
+	Kind    string          `json:"kind"`
+	Height  int64           `json:"height"`
+	Source  string          `json:"source"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Every kind of deferred effect is kept under its own key prefix, followed by
+// the height the effect is due at.
+func deferredKindPrefix(network *types.NetworkIdentifier, kind string) storeKey {
+	return newStoreKey(deferredNamespace).str(kind).network(network)
+}
+
+func deferredKey(network *types.NetworkIdentifier, kind string, height int64, source string) []byte {
+	return deferredKindPrefix(network, kind).int64(height).str(source)
+}
+
+// parseDeferredKey decodes the fields of a key built by deferredKey.
+func parseDeferredKey(key []byte) (kind string, network *types.NetworkIdentifier, height int64, source string, err error) {
+	reader := newKeyReader(key, deferredNamespace)
+	kind = reader.str()
+	network = reader.network()
+	height = reader.int64()
+	source = reader.str()
+	return kind, network, height, source, reader.done()
+}
+
+// PutDeferredEffect stores effect, or returns badger.ErrBannedKey if an
+// effect of the same kind from the same source is already due at its height.
+func PutDeferredEffect(network *types.NetworkIdentifier, effect *DeferredEffect) error {
+	effectBytes, merr := json.Marshal(effect)
+	if merr != nil {
+		return merr
+	}
+
+	key := deferredKey(network, effect.Kind, effect.Height, effect.Source)
+
+	return DB.Update(func(txn *badger.Txn) error {
+		_, gerr := txn.Get(key)
+		if gerr == nil {
+			return badger.ErrBannedKey
+		} else if gerr != badger.ErrKeyNotFound {
+			return gerr
+		}
+		return txn.Set(key, effectBytes)
+	})
+}
+
+// GetDeferredEffect returns a stored effect, or badger.ErrKeyNotFound. An
+// effect that does not decode is quarantined and returned as a
+// QuarantineError.
+func GetDeferredEffect(network *types.NetworkIdentifier, kind string, height int64, source string) (*DeferredEffect, error) {
+	var effect DeferredEffect
+	var decodeErr error
+
+	key := deferredKey(network, kind, height, source)
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(key)
+		if gerr != nil {
+			return gerr
+		}
+		return item.Value(func(val []byte) error {
+			decodeErr = json.Unmarshal(val, &effect)
+			return nil
+		})
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	if decodeErr != nil {
+		return nil, quarantineDeferredKeys(network, map[string]error{string(key): decodeErr})
+	}
+
+	return &effect, nil
+}
+
+// scanDeferredEffects decodes every effect under prefix, a prefix of
+// deferredKindPrefix(network, kind). Effects that do not decode are
+// quarantined, and returned as a QuarantineError instead of any effects.
+func scanDeferredEffects(network *types.NetworkIdentifier, kind string, prefix []byte) ([]*DeferredEffect, error) {
+	var effects []*DeferredEffect
+	undecodable := map[string]error{}
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			var effect DeferredEffect
+			var decodeErr error
+			if err := item.Value(func(val []byte) error {
+				decodeErr = json.Unmarshal(val, &effect)
+				return nil
+			}); err != nil {
+				return fmt.Errorf("reading deferred effect %x: %w", item.Key(), err)
+			}
+
+			if decodeErr == nil && effect.Kind != kind {
+				decodeErr = fmt.Errorf("stored under kind %q but has kind %q", kind, effect.Kind)
+			}
+			if decodeErr != nil {
+				undecodable[string(item.KeyCopy(nil))] = decodeErr
+				continue
+			}
+
+			effects = append(effects, &effect)
+		}
+		return nil
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	if len(undecodable) > 0 {
+		// Undecodable effects that were dropped meanwhile leave the rest
+		if err := quarantineDeferredKeys(network, undecodable); err != badger.ErrKeyNotFound {
+			return nil, err
+		}
+	}
+
+	return effects, nil
+}
+
+// quarantineDeferredKeys moves the deferred effects under keys, whose
+// decoding failed with the mapped errors, into quarantine.
+func quarantineDeferredKeys(network *types.NetworkIdentifier, keys map[string]error) error {
+	candidates := map[string]*quarantineCandidate{}
+
+	for key, cause := range keys {
+		_, _, height, _, perr := parseDeferredKey([]byte(key))
+		if perr != nil {
+			return fmt.Errorf("malformed deferred effect key %x: %w", key, perr)
+		}
+
+		candidates[key] = &quarantineCandidate{height: height, cause: fmt.Errorf("decoding deferred effect: %w", cause)}
+	}
+
+	qerr := quarantineRecords(network, candidates)
+
+	var quarantineErr *QuarantineError
+	if !errors.As(qerr, &quarantineErr) && qerr != badger.ErrKeyNotFound {
+		return fmt.Errorf("quarantining %d undecodable deferred effects: %w", len(keys), qerr)
+	}
+
+	return qerr
+}
+
+// SeekDeferredEffects returns every effect of kind due at height.
+func SeekDeferredEffects(network *types.NetworkIdentifier, kind string, height int64) ([]*DeferredEffect, error) {
+	return scanDeferredEffects(network, kind, deferredKindPrefix(network, kind).int64(height))
+}
+
+// ListDeferredEffects returns every stored effect of kind, ordered by the
+// height it is due at.
+func ListDeferredEffects(network *types.NetworkIdentifier, kind string) ([]*DeferredEffect, error) {
+	effects, err := scanDeferredEffects(network, kind, deferredKindPrefix(network, kind))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(effects, func(a, b int) bool {
+		return effects[a].Height < effects[b].Height
+	})
+
+	return effects, nil
+}
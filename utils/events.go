@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// The block event log is not keyed by parser version: it records which
+// blocks were seen, not how they were parsed, so its sequence numbers stay
+// valid across parser upgrades.
+func eventSequenceKey(network *types.NetworkIdentifier, sequence int64) []byte {
+	return newStoreKey(eventSequenceNamespace).network(network).int64(sequence)
+}
+
+func eventBlockKey(network *types.NetworkIdentifier, height int64) []byte {
+	return newStoreKey(eventBlockNamespace).network(network).int64(height)
+}
+
+func eventHeadKey(network *types.NetworkIdentifier) []byte {
+	return newStoreKey(eventHeadNamespace).network(network)
+}
+
+func eventNextSequenceKey(network *types.NetworkIdentifier) []byte {
+	return newStoreKey(eventNextSequenceNamespace).network(network)
+}
+
+func getInt64(txn *badger.Txn, key []byte) (int64, error) {
+	item, gerr := txn.Get(key)
+	if gerr != nil {
+		return 0, gerr
+	}
+
+	valueBytes, cerr := item.ValueCopy(nil)
+	if cerr != nil {
+		return 0, cerr
+	}
+
+	return strconv.ParseInt(string(valueBytes), 10, 64)
+}
+
+func getEventBlockHash(txn *badger.Txn, network *types.NetworkIdentifier, height int64) (string, error) {
+	item, gerr := txn.Get(eventBlockKey(network, height))
+	if gerr != nil {
+		return "", gerr
+	}
+
+	hash, cerr := item.ValueCopy(nil)
+	if cerr != nil {
+		return "", cerr
+	}
+
+	return string(hash), nil
+}
+
+// AddBlockEvent appends a block_added event for block to the event log.
+// Blocks already in the log, and blocks older than the log, are skipped. If
+// the log holds a different block at block's height, that block and every
+// block above it are first removed from the head down, each with a
+// block_removed event.
+func AddBlockEvent(network *types.NetworkIdentifier, block *types.BlockIdentifier) error {
+	return DB.Update(func(txn *badger.Txn) error {
+		head, herr := getInt64(txn, eventHeadKey(network))
+		if herr == badger.ErrKeyNotFound {
+			head = -1
+		} else if herr != nil {
+			return herr
+		}
+
+		nextSequence, serr := getInt64(txn, eventNextSequenceKey(network))
+		if serr == badger.ErrKeyNotFound {
+			nextSequence = 0
+		} else if serr != nil {
+			return serr
+		}
+
+		// Blocks below the head that the log never recorded predate it
+		if block.Index <= head {
+			hash, gerr := getEventBlockHash(txn, network, block.Index)
+			if gerr == badger.ErrKeyNotFound || (gerr == nil && hash == block.Hash) {
+				return nil
+			} else if gerr != nil {
+				return gerr
+			}
+		}
+
+		appendEvent := func(event *types.BlockEvent) error {
+			event.Sequence = nextSequence
+			eventBytes, merr := json.Marshal(event)
+			if merr != nil {
+				return merr
+			}
+			if err := txn.Set(eventSequenceKey(network, nextSequence), eventBytes); err != nil {
+				return err
+			}
+			nextSequence++
+			return nil
+		}
+
+		for height := head; height >= block.Index; height-- {
+			hash, gerr := getEventBlockHash(txn, network, height)
+			if gerr == badger.ErrKeyNotFound {
+				continue
+			} else if gerr != nil {
+				return gerr
+			}
+
+			if err := appendEvent(&types.BlockEvent{
+				BlockIdentifier: &types.BlockIdentifier{Index: height, Hash: hash},
+				Type:            types.REMOVED,
+			}); err != nil {
+				return err
+			}
+			if err := txn.Delete(eventBlockKey(network, height)); err != nil {
+				return err
+			}
+		}
+
+		if err := appendEvent(&types.BlockEvent{
+			BlockIdentifier: block,
+			Type:            types.ADDED,
+		}); err != nil {
+			return err
+		}
+		if err := txn.Set(eventBlockKey(network, block.Index), []byte(block.Hash)); err != nil {
+			return err
+		}
+		if err := txn.Set(eventHeadKey(network), []byte(strconv.FormatInt(block.Index, 10))); err != nil {
+			return err
+		}
+		return txn.Set(eventNextSequenceKey(network), []byte(strconv.FormatInt(nextSequence, 10)))
+	})
+}
+
+// GetBlockEventHash returns the hash of the block the event log holds at
+// height, or badger.ErrKeyNotFound if it holds none.
+func GetBlockEventHash(network *types.NetworkIdentifier, height int64) (string, error) {
+	var hash string
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		h, err := getEventBlockHash(txn, network, height)
+		hash = h
+		return err
+	})
+	if verr != nil {
+		return "", verr
+	}
+
+	return hash, nil
+}
+
+// GetBlockEventHead returns the height of the last block added to the event
+// log, or badger.ErrKeyNotFound if the log is empty.
+func GetBlockEventHead(network *types.NetworkIdentifier) (int64, error) {
+	var head int64
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		h, err := getInt64(txn, eventHeadKey(network))
+		head = h
+		return err
+	})
+	if verr != nil {
+		return 0, verr
+	}
+
+	return head, nil
+}
+
+// GetBlockEvents returns up to limit events starting at sequence offset,
+// along with the highest sequence in the log. It returns
+// badger.ErrKeyNotFound if the log is empty.
+func GetBlockEvents(network *types.NetworkIdentifier, offset, limit int64) ([]*types.BlockEvent, int64, error) {
+	var events []*types.BlockEvent
+	var maxSequence int64
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		nextSequence, serr := getInt64(txn, eventNextSequenceKey(network))
+		if serr != nil {
+			return serr
+		}
+		maxSequence = nextSequence - 1
+
+		for sequence := offset; sequence <= maxSequence && int64(len(events)) < limit; sequence++ {
+			item, gerr := txn.Get(eventSequenceKey(network, sequence))
+			if gerr != nil {
+				return gerr
+			}
+
+			var event types.BlockEvent
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &event)
+			}); err != nil {
+				return err
+			}
+			events = append(events, &event)
+		}
+		return nil
+	})
+	if verr != nil {
+		return nil, 0, verr
+	}
+
+	return events, maxSequence, nil
+}
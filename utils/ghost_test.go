@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+func TestGhostTxnHashRoundTrip(t *testing.T) {
+	key := &GhostTxnKey{
+		Network:     testNetwork,
+		Block:       &types.BlockIdentifier{Index: 1156322},
+		Transaction: &types.TransactionIdentifier{Hash: "1Ac9ERfYgbRb_T3x2oGRjMZ2B5a7gQHyx"},
+	}
+
+	hash := GhostTxnHash(key)
+	if hash != "ghost_1156322_1Ac9ERfYgbRb_T3x2oGRjMZ2B5a7gQHyx" {
+		t.Fatalf("GhostTxnHash = %q", hash)
+	}
+
+	parsed, ok := ParseGhostTxnHash(testNetwork, hash)
+	if !ok {
+		t.Fatalf("ParseGhostTxnHash(%q) failed", hash)
+	}
+	if parsed.Block.Index != key.Block.Index || parsed.Transaction.Hash != key.Transaction.Hash || parsed.Network != testNetwork {
+		t.Fatalf("ParseGhostTxnHash(%q) = %+v", hash, parsed)
+	}
+}
+
+func TestParseGhostTxnHashRejects(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"1Ac9ERfYgbRb",
+		"ghost_",
+		"ghost_12",
+		"ghost_12_",
+		"ghost_x_abc",
+		"ghost__abc",
+	} {
+		if _, ok := ParseGhostTxnHash(testNetwork, hash); ok {
+			t.Errorf("ParseGhostTxnHash(%q) succeeded", hash)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"encoding/json"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+const (
+	// HTLCCreateStage is the index entry for the create_htlc_v1
+	// transaction that funded an HTLC address
+	HTLCCreateStage = "create"
+
+	// HTLCRedeemStage is the index entry for the redeem_htlc_v1
+	// transaction that emptied an HTLC address
+	HTLCRedeemStage = "redeem"
+)
+
+// HTLCTxnRef locates a transaction in the HTLC index.
+type HTLCTxnRef struct {
+	Block       *types.BlockIdentifier       `json:"block"`
+	Transaction *types.TransactionIdentifier `json:"transaction"`
+}
+
+func htlcKey(network *types.NetworkIdentifier, address, stage string) []byte {
+	return newStoreKey(htlcNamespace).network(network).str(address).str(stage)
+}
+
+// PutHTLCTxn records the transaction for one stage of an HTLC's lifecycle.
+func PutHTLCTxn(network *types.NetworkIdentifier, address, stage string, ref *HTLCTxnRef) error {
+	refBytes, merr := json.Marshal(ref)
+	if merr != nil {
+		return merr
+	}
+
+	return DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(htlcKey(network, address, stage), refBytes)
+	})
+}
+
+// GetHTLCTxn returns the transaction recorded for one stage of an HTLC's
+// lifecycle, or badger.ErrKeyNotFound if none has been processed.
+func GetHTLCTxn(network *types.NetworkIdentifier, address, stage string) (*HTLCTxnRef, error) {
+	var ref HTLCTxnRef
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(htlcKey(network, address, stage))
+		if gerr != nil {
+			return gerr
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &ref)
+		})
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	return &ref, nil
+}
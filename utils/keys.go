@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// KeyLayoutVersion starts every key in the store. It is followed by a
+// namespace byte naming the kind of record and then the record's key fields.
+// Strings are length prefixed and integers big-endian, so keys sort by their
+// fields in order, heights numerically, and every leading run of fields is a
+// prefix that can be range-scanned.
+const KeyLayoutVersion byte = 1
+
+// MaxKeyStringLength is the longest string a key field can hold, the
+// largest length its two byte prefix encodes.
+const MaxKeyStringLength = 1<<16 - 1
+
+// Namespaces of the record kinds in the store. Values are fixed once
+// released: add new namespaces at the end.
+const (
+	metaNamespace byte = iota + 1
+	blockNamespace
+	blockHashNamespace
+	indexedHeightNamespace
+	searchNamespace
+	eventSequenceNamespace
+	eventBlockNamespace
+	eventHeadNamespace
+	eventNextSequenceNamespace
+	htlcNamespace
+	deferredNamespace
+	quarantineNamespace
+	searchHeightNamespace
+	stateChannelNamespace
+)
+
+// storeKey builds a key field by field. Every method returns a new key, so a
+// shared prefix can be extended more than once.
+type storeKey []byte
+
+func newStoreKey(namespace byte) storeKey {
+	return storeKey{KeyLayoutVersion, namespace}
+}
+
+func (k storeKey) extend(field []byte) storeKey {
+	key := make(storeKey, len(k), len(k)+len(field))
+	copy(key, k)
+	return append(key, field...)
+}
+
+// str panics if s is longer than MaxKeyStringLength, since its length would
+// not round-trip. Callers check strings that come from requests.
+func (k storeKey) str(s string) storeKey {
+	if len(s) > MaxKeyStringLength {
+		panic(fmt.Sprintf("key field of %d bytes is longer than %d", len(s), MaxKeyStringLength))
+	}
+
+	field := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(field, uint16(len(s)))
+	return k.extend(append(field, s...))
+}
+
+// int64 flips the sign bit so that negative values sort before positive ones.
+func (k storeKey) int64(v int64) storeKey {
+	field := make([]byte, 8)
+	binary.BigEndian.PutUint64(field, uint64(v)^(1<<63))
+	return k.extend(field)
+}
+
+func (k storeKey) network(network *types.NetworkIdentifier) storeKey {
+	return k.str(network.Blockchain).str(network.Network)
+}
+
+// keyReader decodes the fields of a key in the order storeKey built them.
+// The first malformed field sets err and every later read returns a zero
+// value.
+type keyReader struct {
+	key []byte
+	err error
+}
+
+// newKeyReader returns a reader for the fields of key, which must have been
+// built in namespace under the current KeyLayoutVersion.
+func newKeyReader(key []byte, namespace byte) *keyReader {
+	if len(key) < 2 || key[0] != KeyLayoutVersion || key[1] != namespace {
+		return &keyReader{err: fmt.Errorf("not a key of namespace %d in layout version %d", namespace, KeyLayoutVersion)}
+	}
+	return &keyReader{key: key[2:]}
+}
+
+func (r *keyReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.key) < n {
+		r.err = fmt.Errorf("key truncated: need %d more bytes, have %d", n, len(r.key))
+		return nil
+	}
+	field := r.key[:n]
+	r.key = r.key[n:]
+	return field
+}
+
+func (r *keyReader) str() string {
+	length := r.take(2)
+	if length == nil {
+		return ""
+	}
+	return string(r.take(int(binary.BigEndian.Uint16(length))))
+}
+
+func (r *keyReader) int64() int64 {
+	field := r.take(8)
+	if field == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(field) ^ (1 << 63))
+}
+
+func (r *keyReader) network() *types.NetworkIdentifier {
+	blockchain := r.str()
+	return &types.NetworkIdentifier{Blockchain: blockchain, Network: r.str()}
+}
+
+// done returns the first decoding error, or an error if fields are left over.
+func (r *keyReader) done() error {
+	if r.err == nil && len(r.key) > 0 {
+		r.err = fmt.Errorf("%d unexpected bytes at end of key", len(r.key))
+	}
+	return r.err
+}
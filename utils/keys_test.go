@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestKeyInt64Ordering(t *testing.T) {
+	values := []int64{math.MinInt64, -1000, -1, 0, 1, 255, 256, 1000, math.MaxInt64}
+
+	var keys [][]byte
+	for _, v := range values {
+		keys = append(keys, newStoreKey(blockNamespace).int64(v))
+	}
+
+	if !sort.SliceIsSorted(keys, func(a, b int) bool { return bytes.Compare(keys[a], keys[b]) < 0 }) {
+		t.Fatalf("keys of %v do not sort numerically", values)
+	}
+}
+
+func TestKeyStrOrdering(t *testing.T) {
+	// Length prefixes keep a shorter field's entries together rather than
+	// interleaved with those of a longer field it prefixes
+	a := newStoreKey(searchNamespace).str("ab").int64(math.MaxInt64)
+	b := newStoreKey(searchNamespace).str("abc").int64(math.MinInt64)
+	if bytes.Compare(a, b) >= 0 {
+		t.Fatal("every key under \"ab\" should sort before those under \"abc\"")
+	}
+}
+
+func TestKeyReaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		height int64
+	}{
+		{"empty string", "", 0},
+		{"negative height", "alice", -42},
+		{"large height", "bob", math.MaxInt64},
+		{"min height", "carol", math.MinInt64},
+		{"separators", "a/b/{c}", 7},
+		{"longest string", strings.Repeat("x", MaxKeyStringLength), 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := newStoreKey(htlcNamespace).network(testNetwork).str(test.s).int64(test.height)
+
+			reader := &keyReader{key: key[2:]}
+			blockchain, network := reader.str(), reader.str()
+			s, height := reader.str(), reader.int64()
+			if err := reader.done(); err != nil {
+				t.Fatal(err)
+			}
+
+			if blockchain != testNetwork.Blockchain || network != testNetwork.Network || s != test.s || height != test.height {
+				t.Fatalf("got %q %q %q %d", blockchain, network, s, height)
+			}
+		})
+	}
+}
+
+func TestKeyReaderErrors(t *testing.T) {
+	key := newStoreKey(htlcNamespace).str("alice").int64(10)
+
+	truncated := &keyReader{key: key[2 : len(key)-1]}
+	truncated.str()
+	truncated.int64()
+	if truncated.done() == nil {
+		t.Fatal("truncated key: expected an error")
+	}
+
+	trailing := &keyReader{key: key[2:]}
+	trailing.str()
+	if trailing.done() == nil {
+		t.Fatal("unread fields: expected an error")
+	}
+}
+
+func TestKeyStrTooLong(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a string longer than MaxKeyStringLength")
+		}
+	}()
+
+	newStoreKey(htlcNamespace).str(strings.Repeat("x", MaxKeyStringLength+1))
+}
+
+func TestParseDeferredKey(t *testing.T) {
+	kind, network, height, source, err := parseDeferredKey(deferredKey(testNetwork, "unstake", 10, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != "unstake" || *network != *testNetwork || height != 10 || source != "src" {
+		t.Fatalf("got %q %v %d %q", kind, network, height, source)
+	}
+
+	// Keys of another namespace or layout version are rejected
+	for _, key := range [][]byte{
+		htlcKey(testNetwork, "addr", "create"),
+		append([]byte{KeyLayoutVersion + 1}, deferredKey(testNetwork, "unstake", 10, "src")[1:]...),
+		nil,
+	} {
+		if _, _, _, _, err := parseDeferredKey(key); err == nil {
+			t.Fatalf("%x: expected an error", key)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"go.uber.org/zap"
+)
+
+// GhostTxnRebuilder rebuilds the payload of a ghost transaction stored before
+// the deferred effect store, whose operations may no longer match the ones
+// built for it now.
+type GhostTxnRebuilder func(key *GhostTxnKey, legacy *GhostTxnMetadata) (*GhostTxnMetadata, error)
+
+// migration brings a store written by an earlier version up to date, and
+// returns a summary of the records it changed.
+type migration struct {
+	name string
+	run  func(rebuild GhostTxnRebuilder) (string, error)
+}
+
+// migrations run in order, each once per store. Append new migrations at the
+// end: the store only records how many have been applied.
+var migrations = []*migration{
+	{name: "rewrite ghost transactions as deferred effects", run: migrateGhostTxnKeys},
+}
+
+// migrationBatchBytes bounds the records a migration holds in memory at once.
+const migrationBatchBytes = 64 << 20
+
+func migrationsAppliedKey() []byte {
+	return newStoreKey(metaNamespace).str("migrations_applied")
+}
+
+// Migrate runs the migrations the store has not had yet, and returns a line
+// describing each one it ran.
+func Migrate(rebuild GhostTxnRebuilder) ([]string, error) {
+	var applied int64
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		a, err := getInt64(txn, migrationsAppliedKey())
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		applied = a
+		return err
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	var ran []string
+	for i := applied; i < int64(len(migrations)); i++ {
+		summary, err := migrations[i].run(rebuild)
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", i+1, migrations[i].name, err)
+		}
+
+		if err := DB.Update(func(txn *badger.Txn) error {
+			return txn.Set(migrationsAppliedKey(), []byte(strconv.FormatInt(i+1, 10)))
+		}); err != nil {
+			return ran, err
+		}
+
+		ran = append(ran, fmt.Sprintf("migration %d (%s): %s", i+1, migrations[i].name, summary))
+	}
+
+	return ran, nil
+}
+
+type legacyRecord struct {
+	key   []byte
+	value []byte
+}
+
+// migrateGhostTxnKeys moves every ghost transaction stored under the JSON key
+// of earlier versions into the deferred effect store, rebuilding its payload
+// with rebuild. Binary keys start with KeyLayoutVersion and sort before every
+// legacy key, which starts with a printable character.
+//
+// Records whose key is not recognised, or whose payload cannot be rebuilt,
+// are left in place with a warning, so that a stray record does not stop the
+// service from starting.
+func migrateGhostTxnKeys(rebuild GhostTxnRebuilder) (string, error) {
+	rewritten, skipped := 0, 0
+	seek := []byte{KeyLayoutVersion + 1}
+
+	for {
+		var batch []*legacyRecord
+		batchBytes := 0
+
+		verr := DB.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			for it.Seek(seek); it.Valid() && batchBytes < migrationBatchBytes; it.Next() {
+				item := it.Item()
+				value, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				batch = append(batch, &legacyRecord{key: item.KeyCopy(nil), value: value})
+				batchBytes += len(item.Key()) + len(value)
+			}
+			return nil
+		})
+		if verr != nil {
+			return "", verr
+		}
+
+		if len(batch) == 0 {
+			return fmt.Sprintf("%d ghost transactions rewritten, %d unrecognised left in place", rewritten, skipped), nil
+		}
+
+		// Skipped records stay behind, so the next batch starts after this one
+		seek = append(batch[len(batch)-1].key, 0)
+
+		wb := DB.NewWriteBatch()
+		for _, record := range batch {
+			converted, err := binaryGhostTxnRecord(record, rebuild)
+			if err != nil {
+				zap.S().Warn(fmt.Sprintf("Leaving unrecognised record %q in place: %s", record.key, err.Error()))
+				skipped++
+				continue
+			}
+
+			if err := wb.Set(converted.key, converted.value); err != nil {
+				wb.Cancel()
+				return "", err
+			}
+			if err := wb.Delete(record.key); err != nil {
+				wb.Cancel()
+				return "", err
+			}
+			rewritten++
+		}
+		if err := wb.Flush(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// binaryGhostTxnRecord converts a ghost transaction stored under the
+// concatenated JSON of its network, block and transaction identifiers.
+func binaryGhostTxnRecord(record *legacyRecord, rebuild GhostTxnRebuilder) (*legacyRecord, error) {
+	var key GhostTxnKey
+
+	if len(record.key) == 0 || record.key[0] != '{' {
+		return nil, fmt.Errorf("unknown legacy key layout")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(record.key))
+	if err := decoder.Decode(&key.Network); err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(&key.Block); err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(&key.Transaction); err != nil {
+		return nil, err
+	}
+	if key.Network == nil || key.Block == nil || key.Transaction == nil {
+		return nil, fmt.Errorf("incomplete ghost txn key")
+	}
+
+	// Earlier versions credited the release without debiting the cooldown
+	// sub-account, so the payload is rebuilt rather than copied
+	var legacy GhostTxnMetadata
+	if err := json.Unmarshal(record.value, &legacy); err != nil {
+		return nil, err
+	}
+	rebuilt, rerr := rebuild(&key, &legacy)
+	if rerr != nil {
+		return nil, rerr
+	}
+	payload, perr := json.Marshal(rebuilt)
+	if perr != nil {
+		return nil, perr
+	}
+
+	effectBytes, merr := json.Marshal(&DeferredEffect{
+		Kind:    GhostTxnKind,
+		Height:  key.Block.Index,
+		Source:  key.Transaction.Hash,
+		Payload: payload,
+	})
+	if merr != nil {
+		return nil, merr
+	}
+
+	return &legacyRecord{
+		key:   deferredKey(key.Network, GhostTxnKind, key.Block.Index, key.Transaction.Hash),
+		value: effectBytes,
+	}, nil
+}
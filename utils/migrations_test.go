@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+func setRaw(t *testing.T, key, value []byte) {
+	t.Helper()
+
+	if err := DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getRaw returns the value under key, or nil if there is none.
+func getRaw(t *testing.T, key []byte) []byte {
+	t.Helper()
+
+	var value []byte
+	err := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(key)
+		if gerr != nil {
+			return gerr
+		}
+		value, gerr = item.ValueCopy(nil)
+		if value == nil {
+			value = []byte{}
+		}
+		return gerr
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// legacyGhostTxnKey is the key earlier versions stored the ghost transaction
+// of source due at height under.
+func legacyGhostTxnKey(height int64, source string) []byte {
+	var key []byte
+	for _, field := range []interface{}{
+		testNetwork,
+		&types.BlockIdentifier{Index: height, Hash: "block"},
+		&types.TransactionIdentifier{Hash: source},
+	} {
+		fieldBytes, _ := json.Marshal(field)
+		key = append(key, fieldBytes...)
+	}
+	return key
+}
+
+// testRebuild rebuilds a legacy ghost transaction by tagging its metadata,
+// and fails for ones without metadata.
+func testRebuild(key *GhostTxnKey, legacy *GhostTxnMetadata) (*GhostTxnMetadata, error) {
+	if legacy.Metadata == nil {
+		return nil, errors.New("no metadata")
+	}
+	legacy.Metadata["rebuilt"] = key.Transaction.Hash
+	return legacy, nil
+}
+
+func TestMigrateGhostTxnKey(t *testing.T) {
+	openTestDB(t)
+
+	legacyKey := legacyGhostTxnKey(10, "src")
+	setRaw(t, legacyKey, []byte(`{"operations":[],"metadata":{"owner":"alice"}}`))
+
+	if _, err := Migrate(testRebuild); err != nil {
+		t.Fatal(err)
+	}
+
+	if getRaw(t, legacyKey) != nil {
+		t.Fatal("legacy key is still stored")
+	}
+
+	effect, err := GetDeferredEffect(testNetwork, GhostTxnKind, 10, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effect.Kind != GhostTxnKind || effect.Height != 10 || effect.Source != "src" {
+		t.Fatalf("got %+v", effect)
+	}
+
+	var payload GhostTxnMetadata
+	if err := json.Unmarshal(effect.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Metadata["owner"] != "alice" || payload.Metadata["rebuilt"] != "src" {
+		t.Fatalf("payload was not rebuilt: got %+v", payload)
+	}
+}
+
+func TestMigrateLeavesUnrecognisedKeys(t *testing.T) {
+	openTestDB(t)
+
+	// A ghost transaction that cannot be rebuilt stays behind too
+	unknown := [][]byte{[]byte("mystery"), []byte("{not json"), legacyGhostTxnKey(11, "bad")}
+	for _, key := range unknown {
+		setRaw(t, key, []byte(`{"operations":[]}`))
+	}
+	setRaw(t, legacyGhostTxnKey(10, "src"), []byte(`{"operations":[],"metadata":{}}`))
+
+	ran, err := Migrate(testRebuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 || !strings.Contains(ran[0], "1 ghost transactions rewritten, 3 unrecognised") {
+		t.Fatalf("got %v", ran)
+	}
+
+	for _, key := range unknown {
+		if getRaw(t, key) == nil {
+			t.Fatalf("%q was removed", key)
+		}
+	}
+	if _, err := GetDeferredEffect(testNetwork, GhostTxnKind, 10, "src"); err != nil {
+		t.Fatalf("recognised record was not migrated: %v", err)
+	}
+
+	// The migration is recorded, so it does not run again
+	ran, err = Migrate(testRebuild)
+	if err != nil || len(ran) != 0 {
+		t.Fatalf("second run: got %v, %v", ran, err)
+	}
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// QuarantinedRecord is a record that could not be decoded, moved out of its
+// store so that it can be inspected. Until it is dropped, the block at
+// Height fails instead of being served without it.
+type QuarantinedRecord struct {
+	Height int64  `json:"height"`
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+	Error  string `json:"error"`
+	// Time is when the record was quarantined, in unix seconds
+	Time int64 `json:"time"`
+}
+
+// ID identifies a quarantined record as "{height}_{hex of its original key}".
+func (r *QuarantinedRecord) ID() string {
+	return strconv.FormatInt(r.Height, 10) + "_" + hex.EncodeToString(r.Key)
+}
+
+// ParseQuarantineID reverses QuarantinedRecord.ID.
+func ParseQuarantineID(id string) (int64, []byte, error) {
+	parts := strings.SplitN(id, "_", 2)
+	if len(parts) != 2 {
+		return 0, nil, fmt.Errorf("malformed quarantine id %q, expected {height}_{key}", id)
+	}
+
+	height, perr := strconv.ParseInt(parts[0], 10, 64)
+	if perr != nil {
+		return 0, nil, fmt.Errorf("malformed quarantine id %q: %w", id, perr)
+	}
+
+	key, herr := hex.DecodeString(parts[1])
+	if herr != nil {
+		return 0, nil, fmt.Errorf("malformed quarantine id %q: %w", id, herr)
+	}
+
+	return height, key, nil
+}
+
+// QuarantineError is returned when reading a store moved records that could
+// not be decoded into quarantine.
+type QuarantineError struct {
+	Records []*QuarantinedRecord
+}
+
+func (e *QuarantineError) Error() string {
+	var reasons []string
+	for _, record := range e.Records {
+		reasons = append(reasons, record.ID()+": "+record.Error)
+	}
+	return fmt.Sprint(len(e.Records)) + " records quarantined (" + strings.Join(reasons, "; ") + ")"
+}
+
+func quarantinePrefix(network *types.NetworkIdentifier) storeKey {
+	return newStoreKey(quarantineNamespace).network(network)
+}
+
+func quarantineKey(network *types.NetworkIdentifier, height int64, key []byte) []byte {
+	return quarantinePrefix(network).int64(height).str(string(key))
+}
+
+// quarantineRetries bounds how often quarantineRecords retries a commit that
+// conflicted with another read quarantining the same records.
+const quarantineRetries = 3
+
+// quarantine moves the record under key, due at height, into quarantine. If
+// another read quarantined it first, the record it stored is returned; if
+// the record is gone from both places, the record is nil.
+func quarantine(txn *badger.Txn, network *types.NetworkIdentifier, height int64, key []byte, cause error) (*QuarantinedRecord, error) {
+	item, gerr := txn.Get(key)
+	if gerr == badger.ErrKeyNotFound {
+		return quarantinedRecord(txn, network, height, key)
+	} else if gerr != nil {
+		return nil, gerr
+	}
+
+	value, verr := item.ValueCopy(nil)
+	if verr != nil {
+		return nil, verr
+	}
+
+	record := &QuarantinedRecord{
+		Height: height,
+		Key:    key,
+		Value:  value,
+		Error:  cause.Error(),
+		Time:   time.Now().Unix(),
+	}
+
+	recordBytes, merr := json.Marshal(record)
+	if merr != nil {
+		return nil, merr
+	}
+
+	if err := txn.Set(quarantineKey(network, height, key), recordBytes); err != nil {
+		return nil, err
+	}
+
+	return record, txn.Delete(key)
+}
+
+// quarantinedRecord returns the quarantined record of key, or nil.
+func quarantinedRecord(txn *badger.Txn, network *types.NetworkIdentifier, height int64, key []byte) (*QuarantinedRecord, error) {
+	item, gerr := txn.Get(quarantineKey(network, height, key))
+	if gerr == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if gerr != nil {
+		return nil, gerr
+	}
+
+	var record QuarantinedRecord
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &record)
+	}); err != nil {
+		return nil, fmt.Errorf("decoding quarantined record %x: %w", key, err)
+	}
+
+	return &record, nil
+}
+
+// quarantineCandidate is a record that could not be decoded, due at height.
+type quarantineCandidate struct {
+	height int64
+	cause  error
+}
+
+// quarantineRecords moves the records under keys into quarantine and returns
+// a QuarantineError for them. Reads of the same records race to quarantine
+// them: records another read quarantined first are reported as it stored
+// them, and a commit that conflicts with it is retried. If every record is
+// gone, e.g. dropped in the meantime, badger.ErrKeyNotFound is returned.
+func quarantineRecords(network *types.NetworkIdentifier, keys map[string]*quarantineCandidate) error {
+	for attempt := 0; ; attempt++ {
+		quarantineErr := &QuarantineError{}
+
+		uerr := DB.Update(func(txn *badger.Txn) error {
+			for key, candidate := range keys {
+				record, err := quarantine(txn, network, candidate.height, []byte(key), candidate.cause)
+				if err != nil {
+					return err
+				}
+				if record != nil {
+					quarantineErr.Records = append(quarantineErr.Records, record)
+				}
+			}
+			return nil
+		})
+		if uerr == badger.ErrConflict && attempt < quarantineRetries {
+			continue
+		} else if uerr != nil {
+			return uerr
+		}
+
+		if len(quarantineErr.Records) == 0 {
+			return badger.ErrKeyNotFound
+		}
+
+		sort.SliceStable(quarantineErr.Records, func(a, b int) bool {
+			return quarantineErr.Records[a].ID() < quarantineErr.Records[b].ID()
+		})
+
+		return quarantineErr
+	}
+}
+
+// QuarantineDeferredEffect moves a stored effect whose payload its kind
+// cannot decode into quarantine, and returns a QuarantineError for it.
+func QuarantineDeferredEffect(network *types.NetworkIdentifier, effect *DeferredEffect, cause error) error {
+	qerr := quarantineRecords(network, map[string]*quarantineCandidate{
+		string(deferredKey(network, effect.Kind, effect.Height, effect.Source)): {height: effect.Height, cause: cause},
+	})
+
+	var quarantineErr *QuarantineError
+	if !errors.As(qerr, &quarantineErr) {
+		return fmt.Errorf("quarantining deferred effect after %v: %w", cause, qerr)
+	}
+
+	return qerr
+}
+
+func scanQuarantine(prefix []byte) ([]*QuarantinedRecord, error) {
+	var records []*QuarantinedRecord
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var record QuarantinedRecord
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &record)
+			}); err != nil {
+				return fmt.Errorf("decoding quarantined record %q: %w", it.Item().Key(), err)
+			}
+			records = append(records, &record)
+		}
+		return nil
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	return records, nil
+}
+
+// ListQuarantined returns every quarantined record of network, ordered by
+// height.
+func ListQuarantined(network *types.NetworkIdentifier) ([]*QuarantinedRecord, error) {
+	return scanQuarantine(quarantinePrefix(network))
+}
+
+// QuarantinedAt returns the quarantined records due at height.
+func QuarantinedAt(network *types.NetworkIdentifier, height int64) ([]*QuarantinedRecord, error) {
+	return scanQuarantine(quarantinePrefix(network).int64(height))
+}
+
+// DropQuarantined deletes a quarantined record, or returns
+// badger.ErrKeyNotFound.
+func DropQuarantined(network *types.NetworkIdentifier, height int64, key []byte) error {
+	return DB.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(quarantineKey(network, height, key)); err != nil {
+			return err
+		}
+		return txn.Delete(quarantineKey(network, height, key))
+	})
+}
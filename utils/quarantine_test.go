@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestQuarantineConcurrentReads(t *testing.T) {
+	openTestDB(t)
+
+	effect := &DeferredEffect{Kind: GhostTxnKind, Height: 10, Source: "src", Payload: []byte(`"bad"`)}
+	if err := PutDeferredEffect(testNetwork, effect); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every read that finds the record undecodable races to quarantine it,
+	// and each must report it as quarantined
+	const readers = 8
+	errs := make([]error, readers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = QuarantineDeferredEffect(testNetwork, effect, errors.New("bad payload"))
+		}(i)
+	}
+	wg.Wait()
+
+	var id string
+	for i, err := range errs {
+		var quarantineErr *QuarantineError
+		if !errors.As(err, &quarantineErr) || len(quarantineErr.Records) != 1 {
+			t.Fatalf("reader %d: got %v, want a QuarantineError", i, err)
+		}
+		if id == "" {
+			id = quarantineErr.Records[0].ID()
+		} else if quarantineErr.Records[0].ID() != id {
+			t.Fatalf("reader %d: got %s, want %s", i, quarantineErr.Records[0].ID(), id)
+		}
+	}
+
+	records, err := QuarantinedAt(testNetwork, 10)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got %v, %v, want one record", records, err)
+	}
+	if string(records[0].Value) == "" {
+		t.Fatal("quarantined record lost its value")
+	}
+}
+
+func TestQuarantineDroppedRecord(t *testing.T) {
+	openTestDB(t)
+
+	// An effect that is neither stored nor quarantined, e.g. dropped while
+	// it was being read
+	effect := &DeferredEffect{Kind: GhostTxnKind, Height: 10, Source: "src"}
+	err := QuarantineDeferredEffect(testNetwork, effect, errors.New("bad payload"))
+
+	var quarantineErr *QuarantineError
+	if errors.As(err, &quarantineErr) {
+		t.Fatalf("got %v, want no QuarantineError", err)
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+const (
+	// SearchByAccount indexes transactions by the address of
+	// every account their operations touch
+	SearchByAccount = "account"
+
+	// SearchByTransaction indexes transactions by hash
+	SearchByTransaction = "txn"
+
+	// SearchByTransactionType indexes transactions by their
+	// Helium transaction type
+	SearchByTransactionType = "txn_type"
+
+	// SearchByOperationType indexes transactions by the type
+	// of every operation they contain
+	SearchByOperationType = "op_type"
+
+	// SearchBySubAccount indexes transactions by the
+	// "{address}/{sub-account}" of every sub-account they touch
+	SearchBySubAccount = "sub_account"
+
+	// SearchByCurrency indexes transactions by the
+	// "{symbol}/{decimals}" of every currency they move
+	SearchByCurrency = "currency"
+
+	// SearchByStatus indexes transactions by the status
+	// of every operation they contain
+	SearchByStatus = "status"
+
+	// SearchBySuccess indexes transactions by whether their
+	// operations succeeded, as "true" or "false"
+	SearchBySuccess = "success"
+)
+
+// SearchEntry locates a transaction found through the search index.
+type SearchEntry struct {
+	Block       *types.BlockIdentifier
+	Transaction *types.TransactionIdentifier
+}
+
+func searchDimensionPrefix(network *types.NetworkIdentifier, version int, dimension string) storeKey {
+	return newStoreKey(searchNamespace).network(network).int64(int64(version)).str(dimension)
+}
+
+// Every search entry is also listed under the height of its block, so that a
+// block's entries can be deleted when it is invalidated or replaced.
+func searchHeightKey(network *types.NetworkIdentifier, version int, height int64, entryKey []byte) []byte {
+	return searchHeightPrefix(network, version, height).str(string(entryKey))
+}
+
+func searchHeightPrefix(network *types.NetworkIdentifier, version int, height int64) storeKey {
+	return newStoreKey(searchHeightNamespace).network(network).int64(int64(version)).int64(height)
+}
+
+// PutSearchEntries indexes one transaction under value for each of the given
+// dimensions. The block hash is kept as the entry's value. The entries are
+// written in a batch rather than a single badger transaction, which a
+// transaction touching many accounts would outgrow; if it fails part way,
+// DeleteSearchEntries still removes what was written.
+func PutSearchEntries(
+	network *types.NetworkIdentifier,
+	version int,
+	entry *SearchEntry,
+	values map[string][]string,
+) error {
+	wb := DB.NewWriteBatch()
+	defer wb.Cancel()
+	for dimension, dimensionValues := range values {
+		for _, value := range dimensionValues {
+			key := searchDimensionPrefix(network, version, dimension).str(value).
+				int64(entry.Block.Index).str(entry.Transaction.Hash)
+			if err := wb.Set(key, []byte(entry.Block.Hash)); err != nil {
+				return err
+			}
+			if err := wb.Set(searchHeightKey(network, version, entry.Block.Index, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return wb.Flush()
+}
+
+// DeleteSearchEntries removes every entry indexed for the block at height.
+func DeleteSearchEntries(network *types.NetworkIdentifier, version int, height int64) error {
+	prefix := searchHeightPrefix(network, version, height)
+
+	var keys [][]byte
+	verr := DB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if verr != nil {
+		return verr
+	}
+
+	wb := DB.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		reader := &keyReader{key: key[len(prefix):]}
+		entryKey := reader.str()
+		if err := reader.done(); err != nil {
+			return fmt.Errorf("malformed search height key %x: %w", key, err)
+		}
+
+		if err := wb.Delete([]byte(entryKey)); err != nil {
+			return err
+		}
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return wb.Flush()
+}
+
+// ScanSearchEntries returns every transaction indexed under value in
+// dimension, in ascending block order.
+func ScanSearchEntries(
+	network *types.NetworkIdentifier,
+	version int,
+	dimension,
+	value string,
+) ([]*SearchEntry, error) {
+	return scanSearchPrefix(searchDimensionPrefix(network, version, dimension).str(value))
+}
+
+// scanSearchPrefix decodes every entry under the prefix of one indexed value.
+func scanSearchPrefix(prefix []byte) ([]*SearchEntry, error) {
+	var entries []*SearchEntry
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			reader := &keyReader{key: item.Key()[len(prefix):]}
+			height := reader.int64()
+			txnHash := reader.str()
+			if err := reader.done(); err != nil {
+				return fmt.Errorf("malformed search key %q: %w", item.Key(), err)
+			}
+
+			blockHash, verr := item.ValueCopy(nil)
+			if verr != nil {
+				return verr
+			}
+
+			entries = append(entries, &SearchEntry{
+				Block: &types.BlockIdentifier{
+					Index: height,
+					Hash:  string(blockHash),
+				},
+				Transaction: &types.TransactionIdentifier{
+					Hash: txnHash,
+				},
+			})
+		}
+		return nil
+	})
+	if verr != nil {
+		return nil, verr
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+func putTestEntry(t *testing.T, height int64, hash, account string) {
+	t.Helper()
+
+	if err := PutSearchEntries(testNetwork, 1, &SearchEntry{
+		Block:       &types.BlockIdentifier{Index: height, Hash: "block"},
+		Transaction: &types.TransactionIdentifier{Hash: hash},
+	}, map[string][]string{
+		SearchByTransaction: {hash},
+		SearchByAccount:     {account},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteSearchEntries(t *testing.T) {
+	openTestDB(t)
+
+	putTestEntry(t, 10, "t1", "alice")
+	putTestEntry(t, 10, "t2", "alice")
+	putTestEntry(t, 11, "t3", "alice")
+
+	if err := DeleteSearchEntries(testNetwork, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ScanSearchEntries(testNetwork, 1, SearchByAccount, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Transaction.Hash != "t3" {
+		t.Fatalf("account entries after delete: got %v, want only t3", entries)
+	}
+
+	for _, hash := range []string{"t1", "t2"} {
+		entries, err := ScanSearchEntries(testNetwork, 1, SearchByTransaction, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("%s: got %d entries after delete, want 0", hash, len(entries))
+		}
+	}
+
+	// Deleting a height with no entries is not an error
+	if err := DeleteSearchEntries(testNetwork, 1, 12); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+const (
+	// StateChannelExpiryStage is the index entry for the height a state
+	// channel expires at, from its state_channel_open_v1
+	StateChannelExpiryStage = "expiry"
+
+	// StateChannelCloseStage is the index entry for the height of the
+	// state_channel_close_v1 that closed a state channel
+	StateChannelCloseStage = "close"
+)
+
+func stateChannelKey(network *types.NetworkIdentifier, owner, id, stage string) []byte {
+	return newStoreKey(stateChannelNamespace).network(network).str(owner).str(id).str(stage)
+}
+
+// PutStateChannelHeight records the height of one stage of the lifecycle of
+// the state channel id opened by owner.
+func PutStateChannelHeight(network *types.NetworkIdentifier, owner, id, stage string, height int64) error {
+	return DB.Update(func(txn *badger.Txn) error {
+		return txn.Set(stateChannelKey(network, owner, id, stage), []byte(strconv.FormatInt(height, 10)))
+	})
+}
+
+// GetStateChannelHeight returns the height recorded for one stage of a state
+// channel's lifecycle, or badger.ErrKeyNotFound if none has been processed.
+func GetStateChannelHeight(network *types.NetworkIdentifier, owner, id, stage string) (int64, error) {
+	var height int64
+
+	verr := DB.View(func(txn *badger.Txn) error {
+		item, gerr := txn.Get(stateChannelKey(network, owner, id, stage))
+		if gerr != nil {
+			return gerr
+		}
+
+		heightBytes, cerr := item.ValueCopy(nil)
+		if cerr != nil {
+			return cerr
+		}
+
+		h, perr := strconv.ParseInt(string(heightBytes), 10, 64)
+		height = h
+		return perr
+	})
+	if verr != nil {
+		return 0, verr
+	}
+
+	return height, nil
+}
@@ -0,0 +1,27 @@
+package testdb
+
+import (
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/helium/rosetta-helium/utils"
+)
+
+// Open points utils.DB at an in-memory badger for the duration of a test.
+// Tests of package utils itself cannot import it, and keep their own.
+func Open(t *testing.T) {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := utils.DB
+	utils.DB = db
+
+	t.Cleanup(func() {
+		db.Close()
+		utils.DB = previous
+	})
+}
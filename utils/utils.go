@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -24,150 +26,157 @@ type GhostTxnKey struct {
 }
 
 type GhostTxnMetadata struct {
-	Operations []*types.Operation     `json:"operations"`
-	Metadata   map[string]interface{} `json:"metadata"`
+	Operations          []*types.Operation          `json:"operations"`
+	Metadata            map[string]interface{}      `json:"metadata"`
+	RelatedTransactions []*types.RelatedTransaction `json:"related_transactions,omitempty"`
+	// UnstakeBlock is the height of the originating unstake, when known
+	UnstakeBlock int64 `json:"unstake_block,omitempty"`
 }
 
-func GetKeyBytes(key *GhostTxnKey, seekKeyOnly bool) ([]byte, error) {
-	networkBytes, nerr := json.Marshal(key.Network)
-	if nerr != nil {
-		return nil, nerr
+// StoredGhostTxn is a ghost transaction as kept in the store.
+type StoredGhostTxn struct {
+	Key      *GhostTxnKey
+	Metadata *GhostTxnMetadata
+}
+
+// GhostTxnHashPrefix starts the transaction identifier of every ghost
+// transaction, which is "ghost_{release height}_{unstake hash}".
+const GhostTxnHashPrefix = "ghost_"
+
+// GhostTxnHash returns the transaction identifier hash a ghost transaction
+// stored under key is reported with. It depends only on the release height
+// and the hash of the unstake that created the ghost transaction.
+func GhostTxnHash(key *GhostTxnKey) string {
+	return GhostTxnHashPrefix + strconv.FormatInt(key.Block.Index, 10) + "_" + key.Transaction.Hash
+}
+
+// ParseGhostTxnHash returns the key of the ghost transaction identified by
+// hash, or false if hash is not a ghost transaction identifier.
+func ParseGhostTxnHash(network *types.NetworkIdentifier, hash string) (*GhostTxnKey, bool) {
+	if !strings.HasPrefix(hash, GhostTxnHashPrefix) {
+		return nil, false
 	}
 
-	heightBytes, herr := json.Marshal(key.Block)
-	if herr != nil {
-		return nil, herr
+	parts := strings.SplitN(strings.TrimPrefix(hash, GhostTxnHashPrefix), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, false
 	}
 
-	transactionBytes, terr := json.Marshal(key.Transaction)
-	if terr != nil {
-		return nil, terr
+	height, perr := strconv.ParseInt(parts[0], 10, 64)
+	if perr != nil {
+		return nil, false
 	}
 
-	seekKeyBytes := append(networkBytes, heightBytes...)
+	return &GhostTxnKey{
+		Network: network,
+		Block: &types.BlockIdentifier{
+			Index: height,
+		},
+		Transaction: &types.TransactionIdentifier{
+			Hash: parts[1],
+		},
+	}, true
+}
 
-	if seekKeyOnly {
-		return seekKeyBytes, nil
+// GhostTransaction builds the transaction reported for the ghost transaction
+// stored under key. Ghost transactions without stored related transactions
+// link back to the unstake that created them.
+func GhostTransaction(key *GhostTxnKey, txnMetadata *GhostTxnMetadata) *types.Transaction {
+	relatedTransactions := txnMetadata.RelatedTransactions
+	if relatedTransactions == nil {
+		relatedTransactions = []*types.RelatedTransaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: key.Transaction.Hash,
+				},
+				Direction: types.Backward,
+			},
+		}
 	}
 
-	keyBytes := append(seekKeyBytes, transactionBytes...)
-
-	return keyBytes, nil
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: GhostTxnHash(key),
+		},
+		Operations:          txnMetadata.Operations,
+		RelatedTransactions: relatedTransactions,
+		Metadata:            txnMetadata.Metadata,
+	}
 }
 
-func CreateGhostTxn(key *GhostTxnKey, metadata *GhostTxnMetadata) error {
-	keyBytes, kerr := GetKeyBytes(key, false)
-	if kerr != nil {
-		return kerr
-	}
-
-	verr := DB.View(func(txn *badger.Txn) error {
-		_, err := txn.Get(keyBytes)
-		if err == badger.ErrKeyNotFound {
-			return nil
-		} else if err != nil {
-			return err
-		} else {
-			zap.S().Info("cannot create new ghost txn, badger db entry already exists")
-			return badger.ErrBannedKey
-		}
-	})
-	if verr != nil {
-		return verr
+// GhostTxnKind is the deferred effect kind unstake ghost transactions are
+// stored as.
+const GhostTxnKind = "unstake"
+
+// GhostTxnFromEffect decodes a ghost transaction stored as a deferred effect
+// for network.
+func GhostTxnFromEffect(network *types.NetworkIdentifier, effect *DeferredEffect) (*StoredGhostTxn, error) {
+	var txnMetadata GhostTxnMetadata
+	if err := json.Unmarshal(effect.Payload, &txnMetadata); err != nil {
+		return nil, err
 	}
 
+	return &StoredGhostTxn{
+		Key: &GhostTxnKey{
+			Network:     network,
+			Block:       &types.BlockIdentifier{Index: effect.Height},
+			Transaction: &types.TransactionIdentifier{Hash: effect.Source},
+		},
+		Metadata: &txnMetadata,
+	}, nil
+}
+
+func CreateGhostTxn(key *GhostTxnKey, metadata *GhostTxnMetadata) error {
 	metadataBytes, merr := json.Marshal(metadata)
 	if merr != nil {
 		return merr
 	}
 
-	uerr := DB.Update(func(txn *badger.Txn) error {
-		err := txn.Set(keyBytes, metadataBytes)
-		return err
+	perr := PutDeferredEffect(key.Network, &DeferredEffect{
+		Kind:    GhostTxnKind,
+		Height:  key.Block.Index,
+		Source:  key.Transaction.Hash,
+		Payload: metadataBytes,
 	})
-	if uerr != nil {
-		return uerr
+	if perr == badger.ErrBannedKey {
+		zap.S().Info("cannot create new ghost txn, badger db entry already exists")
 	}
 
-	return nil
+	return perr
 }
 
 func GetGhostTxn(key *GhostTxnKey) (*GhostTxnMetadata, error) {
-	keyBytes, kerr := GetKeyBytes(key, false)
-	if kerr != nil {
-		return nil, kerr
+	effect, err := GetDeferredEffect(key.Network, GhostTxnKind, key.Block.Index, key.Transaction.Hash)
+	if err != nil {
+		return nil, err
 	}
 
-	var txnMetadata GhostTxnMetadata
-
-	verr := DB.View(func(txn *badger.Txn) error {
-		item, gerr := txn.Get(keyBytes)
-		if gerr != nil {
-			return gerr
-		}
-
-		ierr := item.Value(func(val []byte) error {
-			if terr := json.Unmarshal(val, &txnMetadata); terr != nil {
-				return terr
-			}
-			return nil
-		})
-		if ierr != nil {
-			return ierr
-		}
-		return nil
-	})
-	if verr != nil {
-		return nil, verr
+	ghostTxn, gerr := GhostTxnFromEffect(key.Network, effect)
+	if gerr != nil {
+		return nil, QuarantineDeferredEffect(key.Network, effect, gerr)
 	}
 
-	return &txnMetadata, nil
+	return ghostTxn.Metadata, nil
 }
 
-func SeekGhostTxnsInBlock(network *types.NetworkIdentifier, block_height int64) ([]*types.Transaction, error) {
-	var transactions []*types.Transaction
+// ListGhostTxns returns every ghost transaction stored for network, ordered
+// by release height.
+func ListGhostTxns(network *types.NetworkIdentifier) ([]*StoredGhostTxn, error) {
+	effects, err := ListDeferredEffects(network, GhostTxnKind)
+	if err != nil {
+		return nil, err
+	}
 
-	seekKeyBytes, kerr := GetKeyBytes(
-		&GhostTxnKey{
-			Network: network,
-			Block: &types.BlockIdentifier{
-				Index: block_height,
-			},
-			Transaction: nil,
-		},
-		true,
-	)
-	if kerr != nil {
-		return nil, kerr
-	}
-
-	DB.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		for it.Seek(seekKeyBytes); it.ValidForPrefix(seekKeyBytes); it.Next() {
-			item := it.Item()
-			var txnMetadata GhostTxnMetadata
-			err := item.Value(func(v []byte) error {
-				if terr := json.Unmarshal(v, &txnMetadata); terr != nil {
-					return terr
-				}
-
-				transactions = append(transactions, &types.Transaction{
-					TransactionIdentifier: &types.TransactionIdentifier{
-						Hash: string(item.Key()),
-					},
-					Operations: txnMetadata.Operations,
-				})
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+	var ghostTxns []*StoredGhostTxn
+	for _, effect := range effects {
+		ghostTxn, gerr := GhostTxnFromEffect(network, effect)
+		if gerr != nil {
+			return nil, QuarantineDeferredEffect(network, effect, gerr)
 		}
-		return nil
-	})
+		ghostTxns = append(ghostTxns, ghostTxn)
+	}
 
-	return transactions, nil
+	return ghostTxns, nil
 }
 
 func JsonNumberToInt64(m interface{}) int64 {
@@ -175,9 +184,29 @@ func JsonNumberToInt64(m interface{}) int64 {
 	return convertedInt
 }
 
+const (
+	// NodeNotFoundCode is the JSON-RPC error code blockchain-node answers
+	// with when the requested object does not exist
+	NodeNotFoundCode = -100
+
+	// NodeMethodNotFoundCode is the JSON-RPC error code for a method the
+	// node does not implement
+	NodeMethodNotFoundCode = -32601
+)
+
+// DecodeCallAsNumber decodes a node call's result with numbers as
+// json.Number. A not found error from the node decodes as a nil result, and
+// any other JSON-RPC error is returned as the *jsonrpc.RPCError.
 func DecodeCallAsNumber(call *jsonrpc.RPCResponse, err error) (map[string]interface{}, error) {
 	if err != nil {
-		return nil, errors.New("unable to decode json-rpc response")
+		return nil, fmt.Errorf("unable to decode json-rpc response: %w", err)
+	}
+
+	if call.Error != nil {
+		if call.Error.Code == NodeNotFoundCode {
+			return nil, nil
+		}
+		return nil, call.Error
 	}
 
 	stringResult, serr := json.Marshal(call.Result)
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ybbus/jsonrpc"
+)
+
+func TestDecodeCallAsNumber(t *testing.T) {
+	result, err := DecodeCallAsNumber(&jsonrpc.RPCResponse{Result: map[string]interface{}{"stake": 10}}, nil)
+	if err != nil || result["stake"] != json.Number("10") {
+		t.Fatalf("result: got %v, %v", result, err)
+	}
+
+	result, err = DecodeCallAsNumber(&jsonrpc.RPCResponse{Error: &jsonrpc.RPCError{Code: NodeNotFoundCode}}, nil)
+	if err != nil || result != nil {
+		t.Fatalf("not found: got %v, %v, want nil result", result, err)
+	}
+
+	_, err = DecodeCallAsNumber(&jsonrpc.RPCResponse{Error: &jsonrpc.RPCError{Code: NodeMethodNotFoundCode, Message: "Method not found"}}, nil)
+	var rpcErr *jsonrpc.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != NodeMethodNotFoundCode {
+		t.Fatalf("method not found: got %v, want the RPC error", err)
+	}
+
+	if _, err = DecodeCallAsNumber(nil, errors.New("connection refused")); err == nil {
+		t.Fatal("transport error: got nil error")
+	}
+}